@@ -45,7 +45,37 @@ func (s *Service) Ping(ctx context.Context) string {
 }
 
 // DoRequest performs an authenticated HTTP request against a service.
-func (s *Service) DoRequest(ctx context.Context, method, path string, query map[string]string, body []byte) ([]byte, int, error) {
+func (s *Service) DoRequest(ctx context.Context, method, path string, query url.Values, body []byte) ([]byte, int, error) {
+	req, err := s.NewRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Hard ceiling on what we will hold in memory. The configurable
+	// max_response_size_kb guard runs later and protects the model's context;
+	// this protects the process itself, so it is deliberately far above any
+	// legitimate *arr response rather than a second tuning knob.
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxReadBytes+1))
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("reading response: %w", err)
+	}
+	if len(respBody) > maxReadBytes {
+		return nil, resp.StatusCode, fmt.Errorf(
+			"response from %s exceeds the %dMB read limit", s.Name, maxReadBytes>>20)
+	}
+
+	return respBody, resp.StatusCode, nil
+}
+
+// NewRequest builds the authenticated request DoRequest sends, so a dry run
+// can show it without sending it.
+func (s *Service) NewRequest(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Request, error) {
 	reqURL := s.BaseURL + path
 
 	var bodyReader io.Reader
@@ -55,7 +85,7 @@ func (s *Service) DoRequest(ctx context.Context, method, path string, query map[
 
 	req, err := http.NewRequestWithContext(ctx, method, reqURL, bodyReader)
 	if err != nil {
-		return nil, 0, fmt.Errorf("creating request: %w", err)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	if method == "POST" || method == "PUT" || method == "PATCH" || method == "DELETE" {
@@ -69,30 +99,10 @@ func (s *Service) DoRequest(ctx context.Context, method, path string, query map[
 	// Apply query params
 	if len(query) > 0 {
 		q := req.URL.Query()
-		for k, v := range query {
-			q.Set(k, v)
+		for k, vs := range query {
+			q[k] = vs
 		}
 		req.URL.RawQuery = q.Encode()
 	}
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, 0, fmt.Errorf("executing request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Hard ceiling on what we will hold in memory. The configurable
-	// max_response_size_kb guard runs later and protects the model's context;
-	// this protects the process itself, so it is deliberately far above any
-	// legitimate *arr response rather than a second tuning knob.
-	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxReadBytes+1))
-	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("reading response: %w", err)
-	}
-	if len(respBody) > maxReadBytes {
-		return nil, resp.StatusCode, fmt.Errorf(
-			"response from %s exceeds the %dMB read limit", s.Name, maxReadBytes>>20)
-	}
-
-	return respBody, resp.StatusCode, nil
+	return req, nil
 }
@@ -19,7 +19,7 @@ func NewService(name string, cfg config.ServiceConfig) *Service {
 		Name:       name,
 		Config:     cfg,
 		BaseURL:    cfg.URL + cfg.APIVersion,
-		StatusPath: config.DefaultStatusPaths[name],
+		StatusPath: config.DefaultStatusPaths[cfg.ServiceType(name)],
 	}
 
 	switch cfg.AuthMethod {
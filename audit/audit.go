@@ -0,0 +1,252 @@
+// Package audit keeps an append-only JSONL record of every call that changed
+// something, so what the assistant did can be reviewed after the fact.
+package audit
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakenesler/navigatorr/config"
+)
+
+// Entry is one recorded call.
+type Entry struct {
+	ID         string              `json:"id"`
+	Time       time.Time           `json:"time"`
+	Client     string              `json:"client,omitempty"` // empty for the stdio caller
+	Tool       string              `json:"tool"`
+	Service    string              `json:"service"`
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Template   string              `json:"template,omitempty"` // spec path template, e.g. /series/{id}
+	Query      map[string][]string `json:"query,omitempty"`
+	Body       any                 `json:"body,omitempty"` // secrets masked
+	Status     int                 `json:"status,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	DurationMS int64               `json:"duration_ms"`
+	Snapshots  []Snapshot          `json:"snapshots,omitempty"` // state before the call, for undo_change
+	Undoes     string              `json:"undoes,omitempty"`    // ID of the entry this call reverted
+}
+
+// Snapshot is the state of one resource before a call changed it.
+type Snapshot struct {
+	Path   string `json:"path"`
+	Before any    `json:"before,omitempty"` // secrets masked
+	Error  string `json:"error,omitempty"`  // why the state could not be read
+}
+
+// Filter selects entries for Query. Zero fields match everything.
+type Filter struct {
+	Service string
+	Tool    string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// Log appends entries to a JSONL file and rotates it by size. The file is
+// opened per write, so a config reload can build a new Log on the same path
+// without the old one holding a handle to a rotated file.
+type Log struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	mu       sync.Mutex
+}
+
+// New returns the Log configured under audit:, or nil when it is disabled.
+// A nil *Log accepts writes and drops them.
+func New(cfg config.AuditConfig) *Log {
+	if cfg.Disabled {
+		return nil
+	}
+	home, _ := os.UserHomeDir()
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(home, ".local", "state", "navigatorr", "audit.jsonl")
+	} else if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		path = filepath.Join(home, rest)
+	}
+	maxMB, maxFiles := cfg.MaxSizeMB, cfg.MaxFiles
+	if maxMB <= 0 {
+		maxMB = 10
+	}
+	if maxFiles <= 0 {
+		maxFiles = 5
+	}
+	return &Log{path: path, maxBytes: int64(maxMB) << 20, maxFiles: maxFiles}
+}
+
+// Path reports where the log is written.
+func (l *Log) Path() string {
+	if l == nil {
+		return ""
+	}
+	return l.path
+}
+
+// Record fills in the entry's ID and time when unset, masks secrets in its
+// body and appends it. It returns the entry's ID.
+func (l *Log) Record(e Entry) (string, error) {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if l == nil {
+		return e.ID, nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Body = Redact(e.Body)
+	for i := range e.Snapshots {
+		e.Snapshots[i].Before = Redact(e.Snapshots[i].Before)
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e.ID, fmt.Errorf("encoding audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return e.ID, fmt.Errorf("creating audit dir: %w", err)
+	}
+	if err := l.rotate(int64(len(line))); err != nil {
+		return e.ID, err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return e.ID, fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(line); err != nil {
+		return e.ID, fmt.Errorf("writing audit log: %w", err)
+	}
+	return e.ID, nil
+}
+
+// rotate shifts audit.jsonl to audit.jsonl.1, .1 to .2 and so on when the
+// next write would take the file past its size limit. The oldest file beyond
+// maxFiles is dropped.
+func (l *Log) rotate(next int64) error {
+	info, err := os.Stat(l.path)
+	if err != nil || info.Size()+next <= l.maxBytes {
+		return nil
+	}
+	os.Remove(l.rotated(l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(l.rotated(i), l.rotated(i+1))
+	}
+	if err := os.Rename(l.path, l.rotated(1)); err != nil {
+		return fmt.Errorf("rotating audit log: %w", err)
+	}
+	return nil
+}
+
+func (l *Log) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// Query returns matching entries, newest first, reading the rotated files
+// too.
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	if l == nil {
+		return nil, nil
+	}
+	l.mu.Lock()
+	files := []string{l.path}
+	for i := 1; i <= l.maxFiles; i++ {
+		files = append(files, l.rotated(i))
+	}
+	var all []Entry
+	for _, name := range files {
+		entries, err := readEntries(name)
+		if err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+		all = append(all, entries...)
+	}
+	l.mu.Unlock()
+
+	var out []Entry
+	for _, e := range all {
+		if f.matches(e) {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+// Get returns the entry with the given ID.
+func (l *Log) Get(id string) (Entry, bool) {
+	entries, _ := l.Query(Filter{})
+	for _, e := range entries {
+		if e.ID == id {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+func (f Filter) matches(e Entry) bool {
+	if f.Service != "" && !strings.EqualFold(e.Service, f.Service) {
+		return false
+	}
+	if f.Tool != "" && e.Tool != f.Tool {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// readEntries reads one log file. A missing file is empty, and a line that
+// does not parse — a write cut short by a crash — is skipped.
+func readEntries(name string) ([]Entry, error) {
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, sc.Err()
+}
+
+// newID returns a sortable, unique entry ID such as 20260102-150405-9f3a1c.
+func newID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jakenesler/navigatorr/config"
+)
+
+func TestRecordRotatesAndQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := New(config.AuditConfig{Path: path, MaxFiles: 2})
+	l.maxBytes = 400 // a couple of entries per file
+
+	base := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		svc := "sonarr"
+		if i%2 == 1 {
+			svc = "radarr"
+		}
+		if _, err := l.Record(Entry{Time: base.Add(time.Duration(i) * time.Minute), Tool: "call_api", Service: svc, Method: "PUT", Path: "/series/1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Fatalf("expected a second rotated file: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatal("rotation kept more files than max_files")
+	}
+
+	all, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || len(all) >= 12 {
+		t.Fatalf("got %d entries; rotation should have dropped the oldest but kept some", len(all))
+	}
+	if !all[0].Time.Equal(base.Add(11 * time.Minute)) {
+		t.Errorf("newest entry first: got %v", all[0].Time)
+	}
+
+	got, _ := l.Query(Filter{Service: "SONARR", Since: base.Add(9 * time.Minute), Limit: 5})
+	if len(got) != 1 || got[0].Service != "sonarr" || !got[0].Time.Equal(base.Add(10*time.Minute)) {
+		t.Errorf("service and since filter: %+v", got)
+	}
+
+	if e, ok := l.Get(all[3].ID); !ok || e.ID != all[3].ID {
+		t.Errorf("Get(%s) = %+v, %v", all[3].ID, e, ok)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	body := map[string]any{
+		"name":   "SABnzbd",
+		"apiKey": "abc",
+		"fields": []any{
+			map[string]any{"name": "password", "value": "hunter2"},
+			map[string]any{"name": "host", "value": "localhost"},
+		},
+		"api_key": "",
+	}
+	got := Redact(body).(map[string]any)
+	if got["apiKey"] != Mask || got["name"] != "SABnzbd" || got["api_key"] != "" {
+		t.Errorf("top-level fields: %v", got)
+	}
+	fields := got["fields"].([]any)
+	if fields[0].(map[string]any)["value"] != Mask || fields[1].(map[string]any)["value"] != "localhost" {
+		t.Errorf("name/value pairs: %v", fields)
+	}
+	if body["apiKey"] != "abc" {
+		t.Error("Redact modified its input")
+	}
+
+	flagged := RedactNames([]any{
+		map[string]any{"name": "key", "value": "x", "privacy": "apiKey"},
+		map[string]any{"name": "url", "value": "y", "type": "password"},
+		map[string]any{"name": "host", "value": "z", "privacy": "normal"},
+		map[string]any{"pin": "1234"},
+	}, map[string]bool{"pin": true}).([]any)
+	for i, want := range []string{Mask, Mask, "z"} {
+		if got := flagged[i].(map[string]any)["value"]; got != want {
+			t.Errorf("flagged pair %d value = %v, want %v", i, got, want)
+		}
+	}
+	if flagged[3].(map[string]any)["pin"] != Mask {
+		t.Error("extra names should be masked")
+	}
+}
+
+func TestUnmask(t *testing.T) {
+	masked := map[string]any{
+		"apiKey": Mask,
+		"fields": []any{
+			map[string]any{"name": "host", "value": "old"},
+			map[string]any{"name": "password", "value": Mask},
+		},
+	}
+	current := map[string]any{
+		"apiKey": "abc",
+		"fields": []any{
+			map[string]any{"name": "password", "value": "hunter2"},
+			map[string]any{"name": "host", "value": "new"},
+		},
+	}
+	got, ok := Unmask(masked, current)
+	if !ok {
+		t.Fatal("every mask has a counterpart")
+	}
+	m := got.(map[string]any)
+	fields := m["fields"].([]any)
+	if m["apiKey"] != "abc" || fields[0].(map[string]any)["value"] != "old" || fields[1].(map[string]any)["value"] != "hunter2" {
+		t.Errorf("Unmask = %v", got)
+	}
+	if _, ok := Unmask(map[string]any{"token": Mask}, map[string]any{}); ok {
+		t.Error("a mask with no current value should not resolve")
+	}
+}
@@ -0,0 +1,151 @@
+package audit
+
+import "strings"
+
+// Mask replaces secret values in the audit log and in tool output.
+const Mask = "********"
+
+// sensitiveNames are matched against field names without regard to case or
+// separators, so api_key, apiKey and ApiKey all match.
+var sensitiveNames = []string{"apikey", "password", "passkey", "token", "secret", "cookie"}
+
+// Sensitive reports whether a field name looks like it holds a secret.
+func Sensitive(name string) bool {
+	n := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+	for _, s := range sensitiveNames {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of a decoded JSON value with the values of sensitive
+// fields masked. *arr provider settings carry secrets as
+// {"name": "apiKey", "value": "..."} pairs, marked with a privacy or type of
+// password or apiKey, so those are masked too.
+func Redact(v any) any {
+	return RedactNames(v, nil)
+}
+
+// RedactNames is Redact that also masks the fields named in extra, such as
+// those a service's OpenAPI spec marks as secret.
+func RedactNames(v any, extra map[string]bool) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, val := range t {
+			if (Sensitive(k) || extra[k]) && val != nil && val != "" {
+				out[k] = Mask
+				continue
+			}
+			out[k] = RedactNames(val, extra)
+		}
+		if secretPair(t) {
+			if val, ok := t["value"]; ok && val != nil && val != "" {
+				out["value"] = Mask
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, val := range t {
+			out[i] = RedactNames(val, extra)
+		}
+		return out
+	}
+	return v
+}
+
+// secretPair reports whether obj is a {"name": ..., "value": ...} setting
+// holding a secret, by its name or by how the *arr apps flag it.
+func secretPair(obj map[string]any) bool {
+	if name, ok := obj["name"].(string); ok && Sensitive(name) {
+		return true
+	}
+	for _, k := range []string{"privacy", "type"} {
+		if flag, ok := obj[k].(string); ok && (strings.EqualFold(flag, "password") || strings.EqualFold(flag, "apiKey")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Masked reports whether v holds Mask anywhere.
+func Masked(v any) bool {
+	switch t := v.(type) {
+	case string:
+		return t == Mask
+	case map[string]any:
+		for _, val := range t {
+			if Masked(val) {
+				return true
+			}
+		}
+	case []any:
+		for _, val := range t {
+			if Masked(val) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Unmask returns a copy of masked with every Mask replaced by the value at the
+// same place in current, so a redacted state can be written back without
+// overwriting secrets with the mask. ok is false when a masked value has no
+// counterpart in current.
+func Unmask(masked, current any) (v any, ok bool) {
+	switch t := masked.(type) {
+	case string:
+		if t != Mask {
+			return t, true
+		}
+		if current == nil {
+			return t, false
+		}
+		return current, true
+	case map[string]any:
+		cur, _ := current.(map[string]any)
+		out := make(map[string]any, len(t))
+		ok = true
+		for k, val := range t {
+			var good bool
+			out[k], good = Unmask(val, cur[k])
+			ok = ok && good
+		}
+		return out, ok
+	case []any:
+		cur, _ := current.([]any)
+		out := make([]any, len(t))
+		ok = true
+		for i, val := range t {
+			var good bool
+			out[i], good = Unmask(val, counterpart(val, cur, i))
+			ok = ok && good
+		}
+		return out, ok
+	}
+	return masked, true
+}
+
+// counterpart finds the element of cur matching val: by name for the
+// {"name": ..., "value": ...} settings lists, whose order is not guaranteed,
+// and by index otherwise.
+func counterpart(val any, cur []any, i int) any {
+	if obj, isObj := val.(map[string]any); isObj {
+		if name, named := obj["name"].(string); named {
+			for _, c := range cur {
+				if co, _ := c.(map[string]any); co != nil && co["name"] == name {
+					return c
+				}
+			}
+			return nil
+		}
+	}
+	if i < len(cur) {
+		return cur[i]
+	}
+	return nil
+}
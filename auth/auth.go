@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/internal"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Client is an authenticated caller of the http transport.
+type Client struct {
+	Name             string
+	token            string
+	tools            map[string]bool // nil allows every tool
+	services         map[string]bool // nil allows every service
+	allowDestructive *bool
+}
+
+// AllowsTool reports whether the client may call the named tool. A nil client
+// is the stdio caller, which is never scoped.
+func (c *Client) AllowsTool(name string) bool {
+	return c == nil || c.tools == nil || c.tools[name]
+}
+
+// AllowsService reports whether the client may address the named service.
+func (c *Client) AllowsService(name string) bool {
+	return c == nil || c.services == nil || c.services[name]
+}
+
+// AllowDestructive resolves the client's allow_destructive override against
+// the global setting.
+func (c *Client) AllowDestructive(global bool) bool {
+	if c == nil || c.allowDestructive == nil {
+		return global
+	}
+	return *c.allowDestructive
+}
+
+type clientKey struct{}
+
+// NewContext returns a copy of ctx carrying the client.
+func NewContext(ctx context.Context, c *Client) context.Context {
+	ctx = internal.WithClient(ctx, c.Name)
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// FromContext returns the client attached by the HTTP middleware, or nil when
+// the request did not come through it.
+func FromContext(ctx context.Context) *Client {
+	c, _ := ctx.Value(clientKey{}).(*Client)
+	return c
+}
+
+// Authenticator maps bearer tokens to the clients configured under clients:.
+type Authenticator struct {
+	mu      sync.RWMutex
+	clients []*Client
+}
+
+// New builds an Authenticator from config.
+func New(clients map[string]config.ClientConfig) *Authenticator {
+	a := &Authenticator{}
+	a.Update(clients)
+	return a
+}
+
+// Update replaces the configured clients, so a config reload can rotate
+// tokens without restarting the listener.
+func (a *Authenticator) Update(clients map[string]config.ClientConfig) {
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]*Client, 0, len(names))
+	for _, name := range names {
+		cc := clients[name]
+		list = append(list, &Client{
+			Name:             name,
+			token:            cc.Token,
+			tools:            toSet(cc.Tools),
+			services:         toSet(cc.Services),
+			allowDestructive: cc.AllowDestructive,
+		})
+	}
+
+	a.mu.Lock()
+	a.clients = list
+	a.mu.Unlock()
+}
+
+func toSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+// Enabled reports whether any clients are configured. Without any, the
+// transport is unauthenticated.
+func (a *Authenticator) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.clients) > 0
+}
+
+// Lookup returns the client owning token, or nil. Every client is compared so
+// the time taken does not reveal how many tokens share a prefix.
+func (a *Authenticator) Lookup(token string) *Client {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var found *Client
+	for _, c := range a.clients {
+		if subtle.ConstantTimeCompare([]byte(c.token), []byte(token)) == 1 {
+			found = c
+		}
+	}
+	return found
+}
+
+// Middleware rejects requests without a known bearer token with 401 and
+// attaches the client to the request context. It passes everything through
+// while no clients are configured.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		client := a.Lookup(strings.TrimSpace(token))
+		if !ok || client == nil {
+			internal.LogContextf(r.Context(), "rejected unauthenticated %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="navigatorr"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), client)))
+	})
+}
+
+// ToolMiddleware enforces the calling client's tool and service scoping before
+// the tool's own handler runs.
+func ToolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		client := FromContext(ctx)
+		if client == nil {
+			return next(ctx, req)
+		}
+
+		name := req.Params.Name
+		if !client.AllowsTool(name) {
+			internal.ErrorContextf(ctx, "refused tool %s: not in the client's tools list", name)
+			return mcp.NewToolResultError(fmt.Sprintf("tool %q is not allowed for client %q", name, client.Name)), nil
+		}
+		if svc := mcp.ParseString(req, "service", ""); svc != "" && !client.AllowsService(svc) {
+			internal.ErrorContextf(ctx, "refused tool %s: service %s not in the client's services list", name, svc)
+			return mcp.NewToolResultError(fmt.Sprintf("service %q is not allowed for client %q", svc, client.Name)), nil
+		}
+
+		internal.LogContextf(ctx, "tool %s", name)
+		return next(ctx, req)
+	}
+}
+
+// FilterTools hides the tools a client may not call from tools/list.
+func FilterTools(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	client := FromContext(ctx)
+	if client == nil {
+		return tools
+	}
+	allowed := make([]mcp.Tool, 0, len(tools))
+	for _, t := range tools {
+		if client.AllowsTool(t.Name) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
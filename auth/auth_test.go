@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/internal"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func testAuthenticator() *Authenticator {
+	return New(map[string]config.ClientConfig{
+		"laptop": {Token: "laptop-token"},
+		"kiosk": {
+			Token:            "kiosk-token",
+			Tools:            []string{"list_services", "call_api"},
+			Services:         []string{"sonarr"},
+			AllowDestructive: boolPtr(false),
+		},
+	})
+}
+
+func TestMiddlewareRejectsUnknownTokens(t *testing.T) {
+	var gotClient string
+	h := testAuthenticator().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotClient = internal.ClientName(r.Context())
+	}))
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantClient string
+	}{
+		{"no header", "", 401, ""},
+		{"unknown token", "Bearer nope", 401, ""},
+		{"wrong scheme", "Basic laptop-token", 401, ""},
+		{"known token", "Bearer laptop-token", 200, "laptop"},
+		{"second client", "Bearer kiosk-token", 200, "kiosk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotClient = ""
+			req := httptest.NewRequest("POST", "/mcp", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if gotClient != tt.wantClient {
+				t.Errorf("client = %q, want %q", gotClient, tt.wantClient)
+			}
+		})
+	}
+}
+
+// With no clients configured the transport stays open, as it was before
+// clients existed.
+func TestMiddlewarePassesThroughWithoutClients(t *testing.T) {
+	h := New(nil).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/mcp", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+}
+
+// Scoping has to refuse before the tool's handler runs, not after.
+func TestToolMiddlewareScoping(t *testing.T) {
+	a := testAuthenticator()
+	kiosk := a.Lookup("kiosk-token")
+	laptop := a.Lookup("laptop-token")
+
+	tests := []struct {
+		name    string
+		client  *Client
+		tool    string
+		args    map[string]any
+		wantRun bool
+	}{
+		{"stdio caller is unscoped", nil, "qbit_manage_torrent", nil, true},
+		{"unscoped client", laptop, "qbit_manage_torrent", map[string]any{"service": "radarr"}, true},
+		{"allowed tool and service", kiosk, "call_api", map[string]any{"service": "sonarr"}, true},
+		{"tool outside list", kiosk, "qbit_manage_torrent", nil, false},
+		{"service outside list", kiosk, "call_api", map[string]any{"service": "radarr"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := false
+			h := ToolMiddleware(func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				ran = true
+				return mcp.NewToolResultText("ok"), nil
+			})
+
+			ctx := context.Background()
+			if tt.client != nil {
+				ctx = NewContext(ctx, tt.client)
+			}
+			req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: tt.tool, Arguments: tt.args}}
+			res, err := h(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ran != tt.wantRun {
+				t.Fatalf("handler ran = %v, want %v", ran, tt.wantRun)
+			}
+			if !tt.wantRun && !res.IsError {
+				t.Error("refusal should be a tool error")
+			}
+		})
+	}
+}
+
+func TestAllowDestructiveOverride(t *testing.T) {
+	a := New(map[string]config.ClientConfig{
+		"inherit": {Token: "a"},
+		"deny":    {Token: "b", AllowDestructive: boolPtr(false)},
+		"allow":   {Token: "c", AllowDestructive: boolPtr(true)},
+	})
+
+	var stdio *Client
+	if !stdio.AllowDestructive(true) || stdio.AllowDestructive(false) {
+		t.Error("stdio caller should follow the global setting")
+	}
+	if !a.Lookup("a").AllowDestructive(true) || a.Lookup("a").AllowDestructive(false) {
+		t.Error("client without an override should follow the global setting")
+	}
+	if a.Lookup("b").AllowDestructive(true) {
+		t.Error("allow_destructive: false should override a global true")
+	}
+	if !a.Lookup("c").AllowDestructive(false) {
+		t.Error("allow_destructive: true should override a global false")
+	}
+}
+
+func TestFilterToolsHidesDisallowedTools(t *testing.T) {
+	tools := []mcp.Tool{{Name: "list_services"}, {Name: "call_api"}, {Name: "qbit_list_torrents"}}
+
+	if got := FilterTools(context.Background(), tools); len(got) != 3 {
+		t.Errorf("stdio caller should see every tool, got %d", len(got))
+	}
+	ctx := NewContext(context.Background(), testAuthenticator().Lookup("kiosk-token"))
+	got := FilterTools(ctx, tools)
+	if len(got) != 2 || got[0].Name != "list_services" || got[1].Name != "call_api" {
+		t.Errorf("kiosk sees %v, want list_services and call_api", got)
+	}
+}
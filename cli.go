@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/tools"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// argFlags collects repeated -arg key=value flags.
+type argFlags []string
+
+func (a *argFlags) String() string     { return strings.Join(*a, ", ") }
+func (a *argFlags) Set(v string) error { *a = append(*a, v); return nil }
+
+// loadTools loads the config and returns the tools RegisterAll registers for
+// it, exactly as an MCP client would see them.
+func loadTools(configPath string) ([]server.ServerTool, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return buildRuntime(context.Background(), cfg).serverTools(tools.NewState()), nil
+}
+
+// runListTools implements `navigatorr tools`: every registered tool with its
+// description and input schema.
+func runListTools(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tools", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "path to config.yaml (default: ~/.config/navigatorr/config.yaml)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	list, err := loadTools(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	for _, t := range list {
+		schema, _ := json.MarshalIndent(t.Tool.InputSchema, "  ", "  ")
+		fmt.Fprintf(stdout, "%s\n  %s\n  %s\n\n", t.Tool.Name, t.Tool.Description, schema)
+	}
+	return 0
+}
+
+// runTool implements `navigatorr tool <name> -arg key=value ...`. It calls the
+// same handler an MCP client would reach and prints the text it would get
+// back. stdin, when non-nil, holds a JSON object of arguments; -arg values are
+// applied on top of it. tty, when non-nil, is the terminal that destructive
+// calls are confirmed on.
+func runTool(args []string, stdin, tty io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tool", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: navigatorr tool <name> [-config path] [-yes] [-arg key=value ...] [< args.json]")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "path to config.yaml (default: ~/.config/navigatorr/config.yaml)")
+	var pairs argFlags
+	fs.Var(&pairs, "arg", "tool argument as key=value; repeatable")
+	yes := fs.Bool("yes", false, "run destructive calls without asking, where confirm_destructive would ask")
+
+	// Accept flags on either side of the tool name.
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	name := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return 2
+	}
+
+	list, err := loadTools(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	var tool *server.ServerTool
+	var names []string
+	for i := range list {
+		names = append(names, list[i].Tool.Name)
+		if list[i].Tool.Name == name {
+			tool = &list[i]
+		}
+	}
+	if tool == nil {
+		fmt.Fprintf(stderr, "unknown tool %q (available: %s)\n", name, strings.Join(names, ", "))
+		return 2
+	}
+
+	arguments, err := toolArguments(tool.Tool, stdin, pairs)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+
+	// A confirmation token is only good in the process that issued it, and
+	// each command is a new process.
+	if _, ok := arguments["confirm"]; ok {
+		fmt.Fprintln(stderr, "error: confirm tokens do not carry over between commands; run the call again with -yes, or answer the prompt")
+		return 2
+	}
+
+	ctx := tools.WithConfirmer(context.Background(), cliConfirmer(*yes, tty, stderr))
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: name, Arguments: arguments}}
+	res, err := tool.Handler(ctx, req)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	out := stdout
+	if res.IsError {
+		out = stderr
+	}
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			fmt.Fprintln(out, tc.Text)
+		}
+	}
+	if res.IsError {
+		return 1
+	}
+	return 0
+}
+
+// cliConfirmer decides destructive calls for runTool: all of them with -yes,
+// each on the terminal's prompt otherwise, and none when there is no
+// terminal to ask. Batch requests run in parallel, so prompts take turns.
+func cliConfirmer(yes bool, tty io.Reader, stderr io.Writer) func(string) bool {
+	var mu sync.Mutex
+	var lines *bufio.Reader
+	if tty != nil {
+		lines = bufio.NewReader(tty)
+	}
+	return func(message string) bool {
+		if yes {
+			return true
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if lines == nil {
+			fmt.Fprintf(stderr, "%s\nNot run: pass -yes to confirm it without a terminal.\n", message)
+			return false
+		}
+		fmt.Fprintf(stderr, "%s\nRun it? [y/N] ", message)
+		answer, _ := lines.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		}
+		return false
+	}
+}
+
+// toolArguments merges a JSON object read from stdin with key=value pairs.
+// Pair values are typed from the tool's input schema, so a number or boolean
+// reaches the handler the way an MCP client would send it.
+func toolArguments(tool mcp.Tool, stdin io.Reader, pairs []string) (map[string]any, error) {
+	arguments := make(map[string]any)
+	if stdin != nil {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading stdin: %w", err)
+		}
+		if strings.TrimSpace(string(data)) != "" {
+			if err := json.Unmarshal(data, &arguments); err != nil {
+				return nil, fmt.Errorf("stdin is not a JSON object: %w", err)
+			}
+		}
+	}
+
+	for _, p := range pairs {
+		key, raw, ok := strings.Cut(p, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("-arg %q: want key=value", p)
+		}
+		v, err := typedArg(tool, key, raw)
+		if err != nil {
+			return nil, fmt.Errorf("-arg %s: %w", key, err)
+		}
+		arguments[key] = v
+	}
+
+	for _, req := range tool.InputSchema.Required {
+		if _, ok := arguments[req]; !ok {
+			return nil, fmt.Errorf("%s needs %s (pass -arg %s=...)", tool.Name, req, req)
+		}
+	}
+	return arguments, nil
+}
+
+func typedArg(tool mcp.Tool, key, raw string) (any, error) {
+	prop, _ := tool.InputSchema.Properties[key].(map[string]any)
+	switch prop["type"] {
+	case "number", "integer":
+		return strconv.ParseFloat(raw, 64)
+	case "boolean":
+		return strconv.ParseBool(raw)
+	case "object", "array":
+		var v any
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return nil, fmt.Errorf("not valid JSON: %w", err)
+		}
+		return v, nil
+	}
+	return raw, nil
+}
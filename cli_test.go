@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeCustomConfig points a custom service at srv. Custom services have no
+// default spec URL, so nothing is fetched.
+func writeCustomConfig(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yml := fmt.Sprintf("services:\n  custom:\n    url: %s\n    api_key: k\n", srv.URL)
+	if err := os.WriteFile(path, []byte(yml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestToolCommandCallsHandler(t *testing.T) {
+	var gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		w.Write([]byte(`[{"id":1,"title":"A"},{"id":2,"title":"B"},{"id":3,"title":"C"}]`))
+	}))
+	defer srv.Close()
+	path := writeCustomConfig(t, srv)
+
+	stdin := strings.NewReader(`{"service":"custom","path":"/series","query":"{\"term\":\"x\"}"}`)
+	var out, errOut bytes.Buffer
+	code := runTool([]string{"call_api", "-config", path, "-arg", "limit=2", "-arg", "fields=title"}, stdin, nil, &out, &errOut)
+	if code != 0 {
+		t.Fatalf("exit %d, stderr:\n%s", code, errOut.String())
+	}
+	if gotQuery != "term=x" {
+		t.Errorf("query from stdin JSON not forwarded: %q", gotQuery)
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(out.Bytes(), &items); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(items) != 2 || items[0]["title"] != "A" || items[0]["id"] != nil {
+		t.Errorf("limit/fields not applied: %v", items)
+	}
+}
+
+func TestToolCommandErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	path := writeCustomConfig(t, srv)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"unknown tool", []string{"-config", path, "nope"}, 2, "call_api, call_api_batch, fetch_more, get_endpoint_details"},
+		{"missing required", []string{"-config", path, "call_api", "-arg", "service=custom"}, 2, "needs path"},
+		{"malformed pair", []string{"-config", path, "call_api", "-arg", "service"}, 2, "want key=value"},
+		{"tool error", []string{"-config", path, "call_api", "-arg", "service=ghost", "-arg", "path=/x"}, 1, "ghost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			if code := runTool(tt.args, nil, nil, &out, &errOut); code != tt.code {
+				t.Errorf("exit %d, want %d (stderr: %s)", code, tt.code, errOut.String())
+			}
+			if !strings.Contains(errOut.String(), tt.want) {
+				t.Errorf("stderr missing %q:\n%s", tt.want, errOut.String())
+			}
+		})
+	}
+}
+
+func TestToolsCommandListsSchemas(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	path := writeCustomConfig(t, srv)
+
+	var out, errOut bytes.Buffer
+	if code := runListTools([]string{"-config", path}, &out, &errOut); code != 0 {
+		t.Fatalf("exit %d: %s", code, errOut.String())
+	}
+	for _, want := range []string{"call_api", "list_services", `"required"`, `"service"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("listing missing %q", want)
+		}
+	}
+}
+
+// Tokens would not survive to a second command, so with confirm_destructive
+// a destructive call runs only on -yes or a "y" at the prompt.
+func TestToolCommandConfirmsInPlace(t *testing.T) {
+	deletes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deletes++
+		}
+		w.Write([]byte(`{"id": 7}`))
+	}))
+	defer srv.Close()
+	path := writeCustomConfig(t, srv)
+	cfg, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, append([]byte("allow_destructive: true\nconfirm_destructive: true\n"), cfg...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	call := []string{"call_api", "-config", path, "-arg", "service=custom", "-arg", "method=DELETE", "-arg", "path=/series/7"}
+
+	tests := []struct {
+		name    string
+		flags   []string
+		tty     io.Reader
+		code    int
+		deletes int
+		want    string
+	}{
+		{"no terminal", nil, nil, 1, 0, "pass -yes"},
+		{"answered no", nil, strings.NewReader("n\n"), 1, 0, "Run it? [y/N]"},
+		{"answered yes", nil, strings.NewReader("y\n"), 0, 1, "Run it? [y/N]"},
+		{"-yes", []string{"-yes"}, nil, 0, 1, ""},
+		{"confirm token", []string{"-arg", "confirm=abc"}, nil, 2, 0, "do not carry over"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deletes = 0
+			var out, errOut bytes.Buffer
+			if code := runTool(append(slices.Clone(call), tt.flags...), nil, tt.tty, &out, &errOut); code != tt.code {
+				t.Errorf("exit %d, want %d (stderr: %s)", code, tt.code, errOut.String())
+			}
+			if deletes != tt.deletes {
+				t.Errorf("%d deletes, want %d", deletes, tt.deletes)
+			}
+			if !strings.Contains(errOut.String(), tt.want) {
+				t.Errorf("stderr missing %q:\n%s", tt.want, errOut.String())
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -13,40 +14,106 @@ import (
 )
 
 type Config struct {
-	Services          map[string]ServiceConfig `yaml:"services"`
-	Transmission      TransmissionConfig       `yaml:"transmission"`
-	QBittorrent       QBittorrentConfig        `yaml:"qbittorrent"`
-	SABnzbd           SABnzbdConfig            `yaml:"sabnzbd"`
-	MaxResponseSizeKB int                      `yaml:"max_response_size_kb"`
-	AllowDestructive  bool                     `yaml:"allow_destructive"`
+	Services           map[string]ServiceConfig `yaml:"services"`
+	Transmission       TransmissionConfig       `yaml:"transmission"`
+	QBittorrent        QBittorrentConfig        `yaml:"qbittorrent"`
+	SABnzbd            SABnzbdConfig            `yaml:"sabnzbd"`
+	MaxResponseSizeKB  int                      `yaml:"max_response_size_kb"`
+	MaxResponseTokens  int                      `yaml:"max_response_tokens"` // token budget for call_api results; derived from max_response_size_kb when unset
+	BatchConcurrency   int                      `yaml:"batch_concurrency"`   // call_api_batch requests in flight at once; 4 when unset
+	AllowDestructive   bool                     `yaml:"allow_destructive"`
+	ConfirmDestructive bool                     `yaml:"confirm_destructive"` // destructive calls return a preview and need confirming
+	DryRun             bool                     `yaml:"dry_run"`             // every mutating call is rendered instead of sent
+	Clients            map[string]ClientConfig  `yaml:"clients"`
+	Policy             PolicyConfig             `yaml:"policy"`
+	Audit              AuditConfig              `yaml:"audit"`
 }
 
 type ServiceConfig struct {
-	URL        string `yaml:"url"`
-	APIKey     string `yaml:"api_key"`
-	AuthMethod string `yaml:"auth_method"` // "header", "query", "basic"
-	AuthHeader string `yaml:"auth_header"` // custom header name, defaults to X-Api-Key
-	AuthPrefix string `yaml:"auth_prefix"` // prefix for the key value, e.g. "Bearer"
-	APIVersion string `yaml:"api_version"` // e.g. "/api/v3"
-	OpenAPIURL string `yaml:"openapi_url"` // override spec URL
+	Type       string  `yaml:"type"` // service type for defaults, e.g. "sonarr"; defaults to the service name
+	URL        string  `yaml:"url"`
+	APIKey     string  `yaml:"api_key"`
+	APIKeyFile string  `yaml:"api_key_file"` // read api_key from this file, e.g. a Docker secret
+	AuthMethod string  `yaml:"auth_method"`  // "header", "query", "basic"
+	AuthHeader string  `yaml:"auth_header"`  // custom header name, defaults to X-Api-Key
+	AuthPrefix string  `yaml:"auth_prefix"`  // prefix for the key value, e.g. "Bearer"
+	APIVersion string  `yaml:"api_version"`  // e.g. "/api/v3"
+	OpenAPIURL string  `yaml:"openapi_url"`  // override spec URL
+	RateLimit  float64 `yaml:"rate_limit"`   // most call_api_batch requests per second; 0 for no limit
+}
+
+// ServiceType returns the type that drives a service's defaults: its type
+// field, or its name when type is omitted, so `sonarr:` needs no type but
+// `sonarr4k:` does.
+func (c ServiceConfig) ServiceType(name string) string {
+	if c.Type != "" {
+		return c.Type
+	}
+	return name
 }
 
 type TransmissionConfig struct {
-	URL      string `yaml:"url"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	URL          string `yaml:"url"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
 }
 
 type QBittorrentConfig struct {
-	URL      string `yaml:"url"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	URL          string `yaml:"url"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
 }
 
 type SABnzbdConfig struct {
-	URL     string `yaml:"url"`
-	APIKey  string `yaml:"api_key"`
-	URLBase string `yaml:"url_base"` // SABnzbd's own url_base, "/sabnzbd" by default
+	URL        string `yaml:"url"`
+	APIKey     string `yaml:"api_key"`
+	APIKeyFile string `yaml:"api_key_file"`
+	URLBase    string `yaml:"url_base"` // SABnzbd's own url_base, "/sabnzbd" by default
+}
+
+// ClientConfig authorizes one caller of the http transport, keyed by client
+// name. Empty Tools or Services allow everything.
+type ClientConfig struct {
+	Token            string   `yaml:"token"`
+	Tools            []string `yaml:"tools"`             // tool names this client may call
+	Services         []string `yaml:"services"`          // services this client may pass as "service"
+	AllowDestructive *bool    `yaml:"allow_destructive"` // overrides the global setting when set
+}
+
+// PolicyConfig decides which calls may run. Rules are checked in order and
+// the first match wins; DefaultPolicyRules follow the configured rules unless
+// defaults is false. A call no rule matches is allowed.
+type PolicyConfig struct {
+	Defaults      *bool        `yaml:"defaults"` // append DefaultPolicyRules; true when omitted
+	Rules         []PolicyRule `yaml:"rules"`
+	RevealSecrets []PolicyRule `yaml:"reveal_secrets"` // calls that may ask for unmasked secrets; effect is ignored
+}
+
+// PolicyRule matches a call by service, method, path and request values.
+// Omitted fields match anything.
+type PolicyRule struct {
+	Effect   string         `yaml:"effect"`   // allow, deny, or destructive (runs only with allow_destructive)
+	Services []string       `yaml:"services"` // service names or types
+	Methods  []string       `yaml:"methods"`  // HTTP methods
+	Path     string         `yaml:"path"`     // glob: * within a segment, /** across segments
+	Body     map[string]any `yaml:"body"`     // dotted field -> value, or a list of accepted values
+	Query    map[string]any `yaml:"query"`    // query parameter -> value, or a list of accepted values
+	Reason   string         `yaml:"reason"`   // shown when the rule refuses a call
+}
+
+// AuditConfig controls the log of mutating calls.
+type AuditConfig struct {
+	Disabled  bool   `yaml:"disabled"`
+	Path      string `yaml:"path"`        // defaults to ~/.local/state/navigatorr/audit.jsonl
+	MaxSizeMB int    `yaml:"max_size_mb"` // rotate past this size; defaults to 10
+	MaxFiles  int    `yaml:"max_files"`   // rotated files to keep; defaults to 5
+}
+
+// UseDefaults reports whether the built-in rules apply.
+func (p PolicyConfig) UseDefaults() bool {
+	return p.Defaults == nil || *p.Defaults
 }
 
 func DefaultConfigPath() string {
@@ -64,45 +131,63 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("reading config %s: %w", path, err)
 	}
 
+	// ${VAR} references are expanded in the parsed node tree before decoding,
+	// so they work in any value and never inside comments.
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if err := expandNode(&doc); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
 	cfg := &Config{
 		Services: make(map[string]ServiceConfig),
 	}
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	if err := doc.Decode(cfg); err != nil {
 		return nil, fmt.Errorf("parsing config %s: %w", path, err)
 	}
+	if err := cfg.readSecretFiles(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
 
 	// Apply defaults for known service types.
 	for name, svc := range cfg.Services {
+		svc.Type = svc.ServiceType(name)
+		typ := svc.Type
 		if svc.AuthMethod == "" {
-			if m, ok := DefaultAuthMethods[name]; ok {
+			if m, ok := DefaultAuthMethods[typ]; ok {
 				svc.AuthMethod = m
 			} else {
 				svc.AuthMethod = "header"
 			}
 		}
 		if svc.AuthHeader == "" {
-			if h, ok := DefaultAuthHeaders[name]; ok {
+			if h, ok := DefaultAuthHeaders[typ]; ok {
 				svc.AuthHeader = h
 			} else if svc.AuthMethod == "header" {
 				svc.AuthHeader = "X-Api-Key"
 			}
 		}
 		if svc.AuthPrefix == "" {
-			if p, ok := DefaultAuthPrefixes[name]; ok {
+			if p, ok := DefaultAuthPrefixes[typ]; ok {
 				svc.AuthPrefix = p
 			}
 		}
 		if svc.APIVersion == "" {
-			if v, ok := DefaultAPIVersions[name]; ok {
+			if v, ok := DefaultAPIVersions[typ]; ok {
 				svc.APIVersion = v
 			}
 		}
 		if svc.OpenAPIURL == "" {
-			if u, ok := DefaultOpenAPIURLs[name]; ok {
+			if u, ok := DefaultOpenAPIURLs[typ]; ok {
 				svc.OpenAPIURL = u
 			}
 		}
-		resolved, err := resolveURL(name, svc.URL)
+		if svc.RateLimit < 0 {
+			return nil, fmt.Errorf("service %q: rate_limit must not be negative", name)
+		}
+		resolved, err := resolveURL(name, typ, svc.URL)
 		if err != nil {
 			return nil, err
 		}
@@ -110,20 +195,84 @@ func Load(path string) (*Config, error) {
 		cfg.Services[name] = svc
 	}
 
+	// Tokens identify clients, so two clients sharing one would make the
+	// second unreachable and its scoping silently never apply.
+	clientNames := make([]string, 0, len(cfg.Clients))
+	for name := range cfg.Clients {
+		clientNames = append(clientNames, name)
+	}
+	sort.Strings(clientNames)
+	tokens := make(map[string]string)
+	for _, name := range clientNames {
+		c := cfg.Clients[name]
+		if c.Token == "" {
+			return nil, fmt.Errorf("client %q: token is required", name)
+		}
+		if other, ok := tokens[c.Token]; ok {
+			return nil, fmt.Errorf("clients %q and %q share a token", other, name)
+		}
+		tokens[c.Token] = name
+	}
+
+	for i, r := range cfg.Policy.Rules {
+		switch r.Effect {
+		case "allow", "deny", "destructive":
+		default:
+			return nil, fmt.Errorf("policy rule %d: effect must be allow, deny or destructive, got %q", i+1, r.Effect)
+		}
+		if r.Path != "" && !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("policy rule %d: path %q must start with /", i+1, r.Path)
+		}
+	}
+	for i, r := range cfg.Policy.RevealSecrets {
+		if r.Path != "" && !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("policy reveal_secrets rule %d: path %q must start with /", i+1, r.Path)
+		}
+	}
+
 	// Default response size guard to 50KB if not set.
 	if cfg.MaxResponseSizeKB <= 0 {
 		cfg.MaxResponseSizeKB = 50
 	}
+	// At about four bytes to a token, so a config that only tuned the size
+	// guard keeps the limit it chose.
+	if cfg.MaxResponseTokens <= 0 {
+		cfg.MaxResponseTokens = cfg.MaxResponseSizeKB * 1024 / 4
+	}
+	if cfg.BatchConcurrency <= 0 {
+		cfg.BatchConcurrency = 4
+	}
 
 	return cfg, nil
 }
 
-// resolveURL normalizes a service URL, filling in the scheme and the service's
-// default port when they are absent. An omitted URL falls back to localhost;
-// list_services reports the resolved URL, so a wrong guess is visible rather
-// than a confusing failure on the first API call.
-func resolveURL(name, raw string) (string, error) {
-	port, known := DefaultPorts[name]
+// readSecretFiles fills api_key and password from their *_file counterparts.
+func (cfg *Config) readSecretFiles() error {
+	var err error
+	for name, svc := range cfg.Services {
+		if svc.APIKey, err = readSecret(fmt.Sprintf("service %q", name), "api_key", svc.APIKey, svc.APIKeyFile); err != nil {
+			return err
+		}
+		cfg.Services[name] = svc
+	}
+	if cfg.Transmission.Password, err = readSecret("transmission", "password", cfg.Transmission.Password, cfg.Transmission.PasswordFile); err != nil {
+		return err
+	}
+	if cfg.QBittorrent.Password, err = readSecret("qbittorrent", "password", cfg.QBittorrent.Password, cfg.QBittorrent.PasswordFile); err != nil {
+		return err
+	}
+	if cfg.SABnzbd.APIKey, err = readSecret("sabnzbd", "api_key", cfg.SABnzbd.APIKey, cfg.SABnzbd.APIKeyFile); err != nil {
+		return err
+	}
+	return nil
+}
+
+// resolveURL normalizes a service URL, filling in the scheme and the default
+// port for the service's type when they are absent. An omitted URL falls back
+// to localhost; list_services reports the resolved URL, so a wrong guess is
+// visible rather than a confusing failure on the first API call.
+func resolveURL(name, svcType, raw string) (string, error) {
+	port, known := DefaultPorts[svcType]
 
 	if raw == "" {
 		if !known {
@@ -1,6 +1,11 @@
 package config
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestResolveURL(t *testing.T) {
 	tests := []struct {
@@ -25,7 +30,7 @@ func TestResolveURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := resolveURL(tt.service, tt.raw)
+			got, err := resolveURL(tt.service, tt.service, tt.raw)
 			if tt.wantErr {
 				if err == nil {
 					t.Fatalf("resolveURL(%q, %q) = %q, want error", tt.service, tt.raw, got)
@@ -41,3 +46,218 @@ func TestResolveURL(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadValidatesClientTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"distinct tokens", "clients:\n  a: {token: one}\n  b: {token: two}\n", ""},
+		{"missing token", "clients:\n  a: {tools: [call_api]}\n", `client "a": token is required`},
+		{"shared token", "clients:\n  a: {token: same}\n  b: {token: same}\n", `clients "a" and "b" share a token`},
+		{"policy effect", "policy:\n  rules:\n    - {effect: block, path: /command}\n", "policy rule 1: effect must be allow, deny or destructive"},
+		{"policy path", "policy:\n  rules:\n    - {effect: deny, path: command}\n", "must start with /"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A second instance of a known type gets that type's defaults through type:,
+// and a service named after its type needs no type at all.
+func TestLoadAppliesDefaultsByType(t *testing.T) {
+	const yml = `services:
+  sonarr:
+    api_key: a
+  sonarr4k:
+    type: sonarr
+    url: 10.0.0.5
+    api_key: b
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	base, fourK := cfg.Services["sonarr"], cfg.Services["sonarr4k"]
+	if base.Type != "sonarr" || fourK.Type != "sonarr" {
+		t.Errorf("types = %q, %q, want sonarr for both", base.Type, fourK.Type)
+	}
+	if fourK.URL != "http://10.0.0.5:8989" {
+		t.Errorf("sonarr4k url = %q, want the sonarr default port applied", fourK.URL)
+	}
+	if fourK.APIVersion != "/api/v3" || fourK.OpenAPIURL != base.OpenAPIURL {
+		t.Errorf("sonarr4k did not get sonarr defaults: %+v", fourK)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("NAV_KEY", "abc123")
+	t.Setenv("NAV_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{"plain string untouched", "http://host:8989", "http://host:8989", ""},
+		{"whole value", "${NAV_KEY}", "abc123", ""},
+		{"embedded", "http://${NAV_KEY}.local", "http://abc123.local", ""},
+		{"default when unset", "${NAV_MISSING:-fallback}", "fallback", ""},
+		{"default when empty", "${NAV_EMPTY:-fallback}", "fallback", ""},
+		{"set value beats default", "${NAV_KEY:-fallback}", "abc123", ""},
+		{"empty default", "${NAV_MISSING:-}", "", ""},
+		{"lone dollar kept", "pa$$word$", "pa$$word$", ""},
+		{"escaped reference", "$${NAV_KEY}", "${NAV_KEY}", ""},
+		{"set but empty", "key=${NAV_EMPTY}", "key=", ""},
+		{"unset is an error", "${NAV_MISSING}", "", "NAV_MISSING is not set"},
+		{"nested default rejected", "${NAV_MISSING:-${NAV_KEY}}", "", "a default cannot reference another variable"},
+		{"unterminated", "${NAV_KEY", "", "unterminated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnv(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expandEnv(%q) err = %v, want %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandEnv(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("expandEnv(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadInterpolatesEnvAndSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "sonarr_key")
+	if err := os.WriteFile(secret, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NAV_RADARR_KEY", "from-env")
+	t.Setenv("NAV_SECRET_DIR", dir)
+
+	yml := `# ${NOT_EXPANDED_IN_COMMENTS}
+services:
+  sonarr:
+    api_key_file: ${NAV_SECRET_DIR}/sonarr_key
+  radarr:
+    api_key: ${NAV_RADARR_KEY}
+    url: ${NAV_RADARR_HOST:-localhost}
+qbittorrent:
+  url: http://localhost:8080
+  password_file: ` + secret + `
+`
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(yml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Services["sonarr"].APIKey; got != "from-file" {
+		t.Errorf("sonarr api_key = %q, want the trimmed file contents", got)
+	}
+	if got := cfg.Services["radarr"].APIKey; got != "from-env" {
+		t.Errorf("radarr api_key = %q, want from-env", got)
+	}
+	if got := cfg.Services["radarr"].URL; got != "http://localhost:7878" {
+		t.Errorf("radarr url = %q, want the default applied", got)
+	}
+	if cfg.QBittorrent.Password != "from-file" {
+		t.Errorf("qbittorrent password = %q, want from-file", cfg.QBittorrent.Password)
+	}
+}
+
+// A reference in a number or boolean field decodes as that type, and a value
+// that looks like a number still lands in a string field as written.
+func TestLoadInterpolatesTypedFields(t *testing.T) {
+	t.Setenv("NAV_KB", "80")
+	t.Setenv("NAV_AD", "true")
+	t.Setenv("NAV_KEY", "0123")
+
+	yml := `max_response_size_kb: ${NAV_KB}
+allow_destructive: ${NAV_AD}
+batch_concurrency: ${NAV_UNSET_CONCURRENCY:-2}
+services:
+  sonarr:
+    api_key: ${NAV_KEY}
+  radarr:
+    api_key: "${NAV_KEY}"
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.MaxResponseSizeKB != 80 || !cfg.AllowDestructive || cfg.BatchConcurrency != 2 {
+		t.Errorf("max_response_size_kb = %d, allow_destructive = %v, batch_concurrency = %d", cfg.MaxResponseSizeKB, cfg.AllowDestructive, cfg.BatchConcurrency)
+	}
+	for _, name := range []string{"sonarr", "radarr"} {
+		if got := cfg.Services[name].APIKey; got != "0123" {
+			t.Errorf("%s api_key = %q, want 0123", name, got)
+		}
+	}
+}
+
+// A missing variable or file has to say which one, or the user is left
+// guessing across a config full of references.
+func TestLoadReportsMissingVariableOrFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr []string
+	}{
+		{"unset variable", "services:\n  sonarr:\n    api_key: ${NAV_DEFINITELY_UNSET}\n", []string{"NAV_DEFINITELY_UNSET", "line 3"}},
+		{"missing file", "sabnzbd:\n  api_key_file: /nonexistent/sab\n", []string{"sabnzbd", "api_key_file", "/nonexistent/sab"}},
+		{"both inline and file", "transmission:\n  password: x\n  password_file: /x\n", []string{"transmission", "not both"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
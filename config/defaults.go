@@ -79,3 +79,30 @@ var DefaultStatusPaths = map[string]string{
 var DefaultAuthPrefixes = map[string]string{
 	"audiobookshelf": "Bearer",
 }
+
+// arrTypes are the service types that share the Servarr API.
+var arrTypes = []string{"sonarr", "radarr", "lidarr", "readarr", "prowlarr"}
+
+// DefaultPolicyRules cover the destructive calls that do not use the DELETE
+// verb. Download client actions are matched by the path their own API uses:
+// /torrents/delete for qBittorrent, /torrent-remove for Transmission and
+// /queue/delete or /history/delete for SABnzbd.
+var DefaultPolicyRules = []PolicyRule{
+	{Effect: "destructive", Methods: []string{"DELETE"}, Reason: "DELETE requests"},
+	{Effect: "destructive", Body: map[string]any{"deleteFiles": true}, Reason: "requests that delete files from disk"},
+	{
+		Effect:   "destructive",
+		Services: arrTypes,
+		Methods:  []string{"POST"},
+		Path:     "/command",
+		Body: map[string]any{"name": []any{
+			"DeleteSeries", "DeleteMovie", "DeleteArtist", "DeleteAuthor", "DeleteBook",
+			"DeleteLogFiles", "DeleteUpdateLogFiles", "CleanUpRecycleBin", "ClearBlocklist",
+		}},
+		Reason: "commands that delete data",
+	},
+	{Effect: "destructive", Services: arrTypes, Methods: []string{"POST", "PUT", "DELETE"}, Path: "/queue/bulk/**", Reason: "bulk queue removals"},
+	{Effect: "destructive", Services: []string{"qbittorrent"}, Path: "/torrents/delete", Reason: "deleting torrents"},
+	{Effect: "destructive", Services: []string{"transmission"}, Path: "/torrent-remove", Reason: "removing torrents"},
+	{Effect: "destructive", Services: []string{"sabnzbd"}, Path: "/*/delete", Reason: "deleting jobs"},
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// expandNode interpolates environment variables into every scalar value of a
+// parsed YAML document. Working on the node tree rather than the raw file keeps
+// comments out of it and lets errors name the offending line.
+func expandNode(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		if !strings.Contains(n.Value, "$") {
+			return nil
+		}
+		v, err := expandEnv(n.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		n.Value = v
+		// The parser tagged the reference !!str, which would keep
+		// max_response_size_kb: ${KB} from decoding into an int. A plain
+		// scalar is resolved again from its new value; a quoted one stays a
+		// string.
+		if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			n.Tag = ""
+		}
+		return nil
+	}
+	for _, c := range n.Content {
+		if err := expandNode(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} with values from the
+// environment. ${VAR} with VAR unset is an error rather than an empty string,
+// so a missing secret fails at startup instead of as a 401 later; VAR set to
+// the empty string gives the empty string, as in a shell. $${ escapes a
+// literal ${, and a $ not followed by { is left alone.
+func expandEnv(s string) (string, error) {
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "$")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		sb.WriteString(s[:i])
+		s = s[i:]
+
+		switch {
+		case strings.HasPrefix(s, "$${"):
+			sb.WriteString("${")
+			s = s[3:]
+		case strings.HasPrefix(s, "${"):
+			end := strings.Index(s, "}")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			// The first } ends the reference, so ${A:-${B}} would read as
+			// ${A:-${B} and a stray }. Defaults are plain text.
+			if strings.Contains(s[2:end], "${") {
+				return "", fmt.Errorf("${ inside %q: a default cannot reference another variable", s[:end+1])
+			}
+			v, err := lookupVar(s[2:end])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+			s = s[end+1:]
+		default:
+			sb.WriteString("$")
+			s = s[1:]
+		}
+	}
+}
+
+// lookupVar resolves the inside of a ${...} reference.
+func lookupVar(expr string) (string, error) {
+	name, def, hasDefault := strings.Cut(expr, ":-")
+	if name == "" {
+		return "", fmt.Errorf("empty variable name in ${%s}", expr)
+	}
+	v, ok := os.LookupEnv(name)
+	switch {
+	case hasDefault && v == "":
+		// :- covers set but empty too, as in a shell.
+		return def, nil
+	case !ok:
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return v, nil
+}
+
+// readSecret returns the inline value, or the trimmed contents of file when
+// it is set. Setting both is rejected so it is never unclear which one wins.
+func readSecret(owner, field, inline, file string) (string, error) {
+	if file == "" {
+		return inline, nil
+	}
+	if inline != "" {
+		return "", fmt.Errorf("%s: set %s or %s_file, not both", owner, field, field)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("%s: reading %s_file: %w", owner, field, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
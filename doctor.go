@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jakenesler/navigatorr/config"
+)
+
+// doctorTimeout bounds each network check, so one dead host cannot hang the
+// whole report.
+const doctorTimeout = 10 * time.Second
+
+// check is one row of the doctor report.
+type check struct {
+	component string // e.g. "sonarr", "qbittorrent"
+	what      string // e.g. "ping", "spec", "login"
+	ok        bool
+	detail    string
+}
+
+// runDoctor implements `navigatorr doctor`: it loads the config, probes every
+// service and download client the way the tools would, and prints a table.
+// It returns the process exit code — non-zero when any check failed.
+func runDoctor(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "path to config.yaml (default: ~/.config/navigatorr/config.yaml)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(stdout, "config: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	checks := diagnose(ctx, buildRuntime(ctx, cfg))
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMPONENT\tCHECK\tSTATUS\tDETAIL")
+	failed := 0
+	for _, c := range checks {
+		status := "ok"
+		if !c.ok {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.component, c.what, status, c.detail)
+	}
+	tw.Flush()
+
+	if failed > 0 {
+		fmt.Fprintf(stdout, "\n%d of %d checks failed\n", failed, len(checks))
+		return 1
+	}
+	fmt.Fprintf(stdout, "\nall %d checks passed\n", len(checks))
+	return 0
+}
+
+// diagnose runs every check concurrently and returns them in a stable order:
+// services by name, then the download clients.
+func diagnose(ctx context.Context, rt *runtime) []check {
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+
+	var probes []func() check
+	for _, name := range rt.registry.List() {
+		svc, _ := rt.registry.Get(name)
+		probes = append(probes,
+			func() check {
+				status := svc.Ping(ctx)
+				return check{name, "ping", status == "ok", svc.Config.URL + " — " + status}
+			},
+			func() check { return specCheck(rt, name) },
+		)
+	}
+	if rt.qbClient != nil {
+		probes = append(probes, func() check {
+			v, err := rt.qbClient.Version(ctx)
+			return resultCheck("qbittorrent", "login", "version "+v, err)
+		})
+	}
+	if rt.txClient != nil {
+		probes = append(probes, func() check {
+			session, err := rt.txClient.SessionGet(ctx)
+			return resultCheck("transmission", "session-get", fmt.Sprintf("version %v", session["version"]), err)
+		})
+	}
+	if rt.sabClient != nil {
+		probes = append(probes, func() check {
+			v, err := rt.sabClient.Version(ctx)
+			return resultCheck("sabnzbd", "version", "version "+v, err)
+		})
+		// mode=version works without a key, so check the key separately.
+		probes = append(probes, func() check {
+			_, err := rt.sabClient.GetQueue(ctx, 0, 1, "", "")
+			return resultCheck("sabnzbd", "api key", "accepted", err)
+		})
+	}
+
+	checks := make([]check, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checks[i] = probe()
+		}()
+	}
+	wg.Wait()
+	return checks
+}
+
+// specCheck reports the fetch, parse and cache state of a service's spec. A
+// service without a spec URL is not a failure: call_api works without one.
+func specCheck(rt *runtime, name string) check {
+	st := rt.specStore.Status(name)
+	switch {
+	case st.URL == "":
+		return check{name, "spec", true, "no openapi_url — docs tools unavailable, call_api still works"}
+	case st.Err != nil:
+		return check{name, "spec", false, st.Err.Error()}
+	case st.Endpoints == 0:
+		return check{name, "spec", false, "spec parsed but has no endpoints"}
+	}
+	cache := "not cached"
+	if st.Cached {
+		cache = "cached " + st.CacheAge.Round(time.Minute).String() + " ago"
+	}
+	return check{name, "spec", true, fmt.Sprintf("%d endpoints, %s", st.Endpoints, cache)}
+}
+
+func resultCheck(component, what, okDetail string, err error) check {
+	if err != nil {
+		return check{component, what, false, oneLine(err.Error())}
+	}
+	return check{component, what, true, okDetail}
+}
+
+// oneLine keeps multi-line error bodies from breaking the table.
+func oneLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const doctorSpec = `{"openapi":"3.0.0","info":{"title":"t","version":"1"},
+  "paths":{"/series":{"get":{"summary":"list","responses":{"200":{"description":"ok"}}}}}}`
+
+// standIns starts one server that answers as Sonarr, its spec host,
+// qBittorrent, Transmission and SABnzbd. sonarrStatus sets what Sonarr's
+// status endpoint returns.
+func standIns(t *testing.T, sonarrStatus int) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/system/status", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(sonarrStatus)
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/spec.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(doctorSpec))
+	})
+	mux.HandleFunc("/api/v2/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Ok."))
+	})
+	mux.HandleFunc("/api/v2/app/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v4.6.7"))
+	})
+	mux.HandleFunc("/transmission/rpc", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":"success","arguments":{"version":"4.0.6"}}`))
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("mode") {
+		case "version":
+			w.Write([]byte(`{"version":"4.3.3"}`))
+		default:
+			w.Write([]byte(`{"queue":{"slots":[]}}`))
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runDoctorAgainst(t *testing.T, srv *httptest.Server) (string, int) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir()) // keep the spec cache out of the real home
+
+	yml := fmt.Sprintf(`services:
+  sonarr:
+    url: %[1]s
+    api_key: k
+    openapi_url: %[1]s/spec.json
+qbittorrent:
+  url: %[1]s
+  username: admin
+  password: p
+transmission:
+  url: %[1]s
+sabnzbd:
+  url: %[1]s
+  url_base: ""
+  api_key: k
+`, srv.URL)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yml), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	code := runDoctor([]string{"-config", path}, &out, &out)
+	return out.String(), code
+}
+
+func TestDoctorAllHealthy(t *testing.T) {
+	out, code := runDoctorAgainst(t, standIns(t, 200))
+	if code != 0 {
+		t.Fatalf("exit code %d, want 0:\n%s", code, out)
+	}
+	for _, want := range []string{
+		"sonarr", "ping", "1 endpoints, cached",
+		"qbittorrent", "v4.6.7",
+		"transmission", "4.0.6",
+		"sabnzbd", "4.3.3",
+		"all 6 checks passed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDoctorReportsFailures(t *testing.T) {
+	out, code := runDoctorAgainst(t, standIns(t, 401))
+	if code == 0 {
+		t.Fatalf("exit code 0 with a rejected API key:\n%s", out)
+	}
+	if !strings.Contains(out, "FAIL") || !strings.Contains(out, "unauthorized") {
+		t.Errorf("report should flag the unauthorized service:\n%s", out)
+	}
+	if !strings.Contains(out, "1 of 6 checks failed") {
+		t.Errorf("report should count the failure:\n%s", out)
+	}
+}
+
+func TestDoctorReportsBadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("services:\n  sonarr:\n    api_key: ${NAV_DOCTOR_UNSET}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if code := runDoctor([]string{"-config", path}, &out, &out); code == 0 {
+		t.Fatal("a config that fails to load should exit non-zero")
+	}
+	if !strings.Contains(out.String(), "NAV_DOCTOR_UNSET") {
+		t.Errorf("report should name the missing variable:\n%s", out.String())
+	}
+}
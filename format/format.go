@@ -0,0 +1,374 @@
+// Package format renders tool results as JSON, a Markdown table, CSV or YAML.
+// A list of torrents or queue slots is several times smaller as a table than
+// as indented JSON, and easier for a person to read too.
+package format
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format names an output format.
+type Format string
+
+const (
+	JSON        Format = "json"         // indented JSON
+	CompactJSON Format = "compact_json" // JSON without whitespace
+	Table       Format = "table"        // Markdown table of the largest list
+	CSV         Format = "csv"          // CSV of the largest list, with a header row
+	YAML        Format = "yaml"
+)
+
+// Names lists the formats for tool descriptions and errors.
+const Names = "json, compact_json, table, csv, yaml"
+
+// Parse reads a format name. An empty name is def.
+func Parse(name string, def Format) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(name))); f {
+	case "":
+		return def, nil
+	case JSON, CompactJSON, Table, CSV, YAML:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid output %q (use: %s)", name, Names)
+}
+
+// Render formats v, which is anything encoding/json can marshal. Object keys
+// keep the order v marshals them in, so a struct's fields come out in
+// declaration order.
+//
+// Table and CSV lay out the largest list in v, one row per item, with nested
+// objects flattened into dotted columns such as statistics.sizeOnDisk. The
+// table puts the scalar fields beside the list, such as page and
+// totalRecords, on lines above it, leaving out empty ones. columns, when
+// given, picks the columns and their order; a column may be written with the
+// list's path in front, as in records.title, the way call_api's fields are.
+func Render(v any, f Format, columns []string) (string, error) {
+	switch f {
+	case JSON, "":
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	case CompactJSON:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+
+	tree, err := decodeOrdered(v)
+	if err != nil {
+		return "", err
+	}
+	switch f {
+	case YAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(yamlNode(tree)); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	case Table, CSV:
+		t := tabulate(tree, columns)
+		if f == CSV {
+			return t.csv()
+		}
+		return t.markdown(), nil
+	}
+	return "", fmt.Errorf("unknown output format %q", f)
+}
+
+// object is a decoded JSON object that remembers its key order.
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+// decodeOrdered round-trips v through JSON into nil, bool, json.Number,
+// string, []any and *object values. Numbers stay as written, so ids and sizes
+// print without an exponent.
+func decodeOrdered(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &object{values: map[string]any{}}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			k := key.(string)
+			if _, dup := obj.values[k]; !dup {
+				obj.keys = append(obj.keys, k)
+			}
+			obj.values[k] = val
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			val, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// yamlNode builds the YAML for a decoded value by hand, since yaml.v3 would
+// sort the keys of a map.
+func yamlNode(v any) *yaml.Node {
+	switch v := v.(type) {
+	case *object:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range v.keys {
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, yamlNode(v.values[k]))
+		}
+		return n
+	case []any:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range v {
+			n.Content = append(n.Content, yamlNode(item))
+		}
+		return n
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v)}
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(string(v), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(v)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v)}
+}
+
+// table is the tabular view of a decoded value.
+type table struct {
+	header  []string // "key: value" lines for the scalars beside the list
+	columns []string
+	rows    [][]string
+}
+
+// tabulate lays out the largest list in v. Without a list, v itself is one
+// row. A list of scalars is one column, value.
+func tabulate(v any, columns []string) table {
+	var t table
+	items, path, parent := largestList(v, "", nil)
+	if items == nil {
+		items = []any{v}
+		parent = nil
+	}
+	if parent != nil {
+		for _, k := range parent.keys {
+			if val := parent.values[k]; isScalar(val) && val != nil && val != "" {
+				t.header = append(t.header, k+": "+cell(val))
+			}
+		}
+	}
+
+	flat := make([]map[string]string, len(items))
+	var seen []string
+	known := map[string]bool{}
+	for i, item := range items {
+		flat[i] = map[string]string{}
+		if _, ok := item.(*object); !ok {
+			flat[i]["value"] = cell(item)
+			if !known["value"] {
+				known["value"] = true
+				seen = append(seen, "value")
+			}
+			continue
+		}
+		flatten(item, "", func(k, val string) {
+			flat[i][k] = val
+			if !known[k] {
+				known[k] = true
+				seen = append(seen, k)
+			}
+		})
+	}
+
+	t.columns = seen
+	if len(columns) > 0 {
+		t.columns = nil
+		for _, c := range columns {
+			if rest, ok := strings.CutPrefix(c, path+"."); ok && path != "" {
+				c = rest
+			} else if !known[c] && parent != nil && parent.values[c] != nil && isScalar(parent.values[c]) {
+				continue // a field beside the list, already in the header
+			}
+			t.columns = append(t.columns, c)
+		}
+	}
+	for _, row := range flat {
+		cells := make([]string, len(t.columns))
+		for i, c := range t.columns {
+			cells[i] = row[c]
+		}
+		t.rows = append(t.rows, cells)
+	}
+	return t
+}
+
+// largestList finds the longest array in v, its dotted path, and the object
+// holding it.
+func largestList(v any, path string, parent *object) ([]any, string, *object) {
+	switch v := v.(type) {
+	case []any:
+		return v, path, parent
+	case *object:
+		var best []any
+		var bestPath string
+		var bestParent *object
+		for _, k := range v.keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			if arr, ap, par := largestList(v.values[k], p, v); arr != nil && (best == nil || len(arr) > len(best)) {
+				best, bestPath, bestParent = arr, ap, par
+			}
+		}
+		return best, bestPath, bestParent
+	}
+	return nil, "", nil
+}
+
+// flatten calls emit for each scalar in v under its dotted key. Lists are
+// one cell: scalars joined with commas, anything else as compact JSON.
+func flatten(v any, prefix string, emit func(k, val string)) {
+	obj, ok := v.(*object)
+	if !ok {
+		emit(prefix, cell(v))
+		return
+	}
+	for _, k := range obj.keys {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		flatten(obj.values[k], key, emit)
+	}
+}
+
+func isScalar(v any) bool {
+	switch v.(type) {
+	case *object, []any:
+		return false
+	}
+	return true
+}
+
+// cell formats a value for one table or CSV cell.
+func cell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return string(v)
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			if !isScalar(item) {
+				data, _ := json.Marshal(plain(v))
+				return string(data)
+			}
+			parts[i] = cell(item)
+		}
+		return strings.Join(parts, ", ")
+	case *object:
+		data, _ := json.Marshal(plain(v))
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// plain turns a decoded value back into ordinary maps for json.Marshal.
+func plain(v any) any {
+	switch v := v.(type) {
+	case *object:
+		m := make(map[string]any, len(v.keys))
+		for _, k := range v.keys {
+			m[k] = plain(v.values[k])
+		}
+		return m
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = plain(item)
+		}
+		return out
+	}
+	return v
+}
+
+func (t table) markdown() string {
+	var b strings.Builder
+	for _, h := range t.header {
+		b.WriteString(h + "\n")
+	}
+	if len(t.header) > 0 {
+		b.WriteString("\n")
+	}
+	if len(t.rows) == 0 || len(t.columns) == 0 {
+		b.WriteString("(no items)")
+		return b.String()
+	}
+	escape := strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ")
+	line := func(cells []string) {
+		for i, c := range cells {
+			cells[i] = escape.Replace(c)
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	line(append([]string(nil), t.columns...))
+	rule := make([]string, len(t.columns))
+	for i := range rule {
+		rule[i] = "---"
+	}
+	line(rule)
+	for _, r := range t.rows {
+		line(r)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (t table) csv() (string, error) {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	if len(t.columns) > 0 {
+		w.Write(t.columns)
+	}
+	w.WriteAll(t.rows)
+	return strings.TrimSuffix(b.String(), "\n"), w.Error()
+}
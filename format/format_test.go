@@ -0,0 +1,70 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+type torrent struct {
+	ID       int     `json:"id"`
+	Name     string  `json:"name"`
+	Progress float64 `json:"progress"`
+	Labels   []string
+}
+
+func TestRender(t *testing.T) {
+	torrents := []torrent{
+		{ID: 1, Name: "Ubuntu | LTS", Progress: 0.5, Labels: []string{"linux", "iso"}},
+		{ID: 2, Name: "Debian", Progress: 1},
+	}
+	envelope := map[string]any{
+		"page": 1, "totalRecords": 2,
+		"records": []any{
+			map[string]any{"id": 7, "title": "Andor", "statistics": map[string]any{"sizeOnDisk": 123456789012}},
+			map[string]any{"id": 8, "title": "Severance"},
+		},
+	}
+
+	for _, tt := range []struct {
+		name    string
+		v       any
+		f       Format
+		columns []string
+		want    string
+	}{
+		{"table keeps struct order", torrents, Table, nil,
+			"| id | name | progress | Labels |\n| --- | --- | --- | --- |\n| 1 | Ubuntu \\| LTS | 0.5 | linux, iso |\n| 2 | Debian | 1 |  |"},
+		{"table of an envelope", envelope, Table, []string{"records.title", "records.statistics.sizeOnDisk", "page"},
+			"page: 1\ntotalRecords: 2\n\n| title | statistics.sizeOnDisk |\n| --- | --- |\n| Andor | 123456789012 |\n| Severance |  |"},
+		{"csv", torrents, CSV, []string{"name", "progress"},
+			"name,progress\nUbuntu | LTS,0.5\nDebian,1"},
+		{"csv of one object", map[string]any{"speed": "1.2 M", "paused": false}, CSV, nil,
+			"paused,speed\nfalse,1.2 M"},
+		{"yaml", torrents[1:], YAML, nil,
+			"- id: 2\n  name: Debian\n  progress: 1\n  Labels: null"},
+		{"compact json", map[string]any{"a": []int{1, 2}}, CompactJSON, nil, `{"a":[1,2]}`},
+		{"empty list", []torrent{}, Table, nil, "(no items)"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.v, tt.f, tt.columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	if f, err := Parse("", CompactJSON); err != nil || f != CompactJSON {
+		t.Errorf("empty = %q, %v", f, err)
+	}
+	if f, err := Parse(" Table ", JSON); err != nil || f != Table {
+		t.Errorf("Table = %q, %v", f, err)
+	}
+	if _, err := Parse("xml", JSON); err == nil || !strings.Contains(err.Error(), "compact_json") {
+		t.Errorf("xml: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -15,3 +16,34 @@ func Logf(format string, args ...any) {
 func Errorf(format string, args ...any) {
 	logger.Output(2, "ERROR: "+fmt.Sprintf(format, args...))
 }
+
+type clientKey struct{}
+
+// WithClient tags ctx with the name of the authenticated network client, so
+// LogContextf lines written while serving it say who they were for.
+func WithClient(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, clientKey{}, name)
+}
+
+// ClientName returns the client name attached by WithClient, or "".
+func ClientName(ctx context.Context) string {
+	name, _ := ctx.Value(clientKey{}).(string)
+	return name
+}
+
+// LogContextf logs like Logf, prefixed with the client name carried by ctx.
+func LogContextf(ctx context.Context, format string, args ...any) {
+	logger.Output(2, clientPrefix(ctx)+fmt.Sprintf(format, args...))
+}
+
+// ErrorContextf logs like Errorf, prefixed with the client name carried by ctx.
+func ErrorContextf(ctx context.Context, format string, args ...any) {
+	logger.Output(2, clientPrefix(ctx)+"ERROR: "+fmt.Sprintf(format, args...))
+}
+
+func clientPrefix(ctx context.Context) string {
+	if name := ClientName(ctx); name != "" {
+		return "[" + name + "] "
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+)
+
+func TestContextLoggingNamesClient(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l *log.Logger) { logger = l }(logger)
+	logger = log.New(&buf, "", 0)
+
+	ctx := WithClient(context.Background(), "laptop")
+	LogContextf(ctx, "tool %s", "call_api")
+	ErrorContextf(ctx, "audit: %v", "disk full")
+	LogContextf(context.Background(), "startup")
+
+	want := "[laptop] tool call_api\n[laptop] ERROR: audit: disk full\nstartup\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -4,25 +4,44 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 
-	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/auth"
 	"github.com/jakenesler/navigatorr/config"
 	"github.com/jakenesler/navigatorr/internal"
-	"github.com/jakenesler/navigatorr/openapi"
-	"github.com/jakenesler/navigatorr/qbit"
-	"github.com/jakenesler/navigatorr/sabnzbd"
-	"github.com/jakenesler/navigatorr/tools"
-	"github.com/jakenesler/navigatorr/transmission"
 	"github.com/mark3labs/mcp-go/server"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "doctor":
+			os.Exit(runDoctor(os.Args[2:], os.Stdout, os.Stderr))
+		case "tools":
+			os.Exit(runListTools(os.Args[2:], os.Stdout, os.Stderr))
+		case "tool", "call":
+			os.Exit(runTool(os.Args[2:], pipedStdin(), terminalStdin(), os.Stdout, os.Stderr))
+		}
+	}
+
 	configPath := flag.String("config", "", "path to config.yaml (default: ~/.config/navigatorr/config.yaml)")
+	transport := flag.String("transport", "stdio", "MCP transport: stdio or http (streamable HTTP and SSE)")
+	listen := flag.String("listen", ":8765", "listen address for the http transport")
 	flag.Parse()
 
+	if *transport != "stdio" && *transport != "http" {
+		fmt.Fprintf(os.Stderr, "error: unknown transport %q (use: stdio, http)\n", *transport)
+		os.Exit(2)
+	}
+
+	path := *configPath
+	if path == "" {
+		path = config.DefaultConfigPath()
+	}
+
 	// Load config
-	cfg, err := config.Load(*configPath)
+	cfg, err := config.Load(path)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
@@ -30,57 +49,41 @@ func main() {
 
 	internal.Logf("loaded config with %d services", len(cfg.Services))
 
-	// Build service registry
-	registry := arrservice.NewRegistry(cfg)
-
-	// Build OpenAPI spec store and load specs
-	specStore := openapi.NewStore(cfg)
+	// Build the service registry, OpenAPI spec store and download clients
 	ctx := context.Background()
-	specStore.LoadAll(ctx)
-
-	// Build Transmission client if configured
-	var txClient *transmission.Client
-	if cfg.Transmission.URL != "" {
-		txClient = transmission.NewClient(
-			cfg.Transmission.URL,
-			cfg.Transmission.Username,
-			cfg.Transmission.Password,
-		)
-		internal.Logf("transmission client configured: %s", cfg.Transmission.URL)
-	}
+	rt := buildRuntime(ctx, cfg)
 
-	// Build qBittorrent client if configured
-	var qbClient *qbit.Client
-	if cfg.QBittorrent.URL != "" {
-		qbClient = qbit.NewClient(
-			cfg.QBittorrent.URL,
-			cfg.QBittorrent.Username,
-			cfg.QBittorrent.Password,
-		)
-		internal.Logf("qbittorrent client configured: %s", cfg.QBittorrent.URL)
+	// Create MCP server
+	opts := []server.ServerOption{
+		server.WithToolCapabilities(true),
+		server.WithElicitation(),
+		server.WithInstructions("Navigatorrr provides tools to browse *arr service API documentation, make authenticated API calls to Sonarr/Radarr/Lidarr/Seerr/etc., manage Transmission torrents, manage qBittorrent torrents, and manage SABnzbd Usenet downloads. Use list_services to see available services, search_api to find endpoints, and call_api to make requests."),
 	}
 
-	// Build SABnzbd client if configured
-	var sabClient *sabnzbd.Client
-	if cfg.SABnzbd.URL != "" {
-		sabClient = sabnzbd.NewClient(
-			cfg.SABnzbd.URL,
-			cfg.SABnzbd.URLBase,
-			cfg.SABnzbd.APIKey,
+	// Network clients are scoped before any tool handler runs. Over stdio
+	// there is no client in the context and both hooks pass through.
+	authn := auth.New(cfg.Clients)
+	if *transport == "http" {
+		opts = append(opts,
+			server.WithToolHandlerMiddleware(auth.ToolMiddleware),
+			server.WithToolFilter(auth.FilterTools),
 		)
-		internal.Logf("sabnzbd client configured: %s", cfg.SABnzbd.URL)
 	}
 
-	// Create MCP server
-	s := server.NewMCPServer(
-		"navigatorr",
-		"1.0.0",
-		server.WithToolCapabilities(true),
-		server.WithInstructions("Navigatorrr provides tools to browse *arr service API documentation, make authenticated API calls to Sonarr/Radarr/Lidarr/Seerr/etc., manage Transmission torrents, manage qBittorrent torrents, and manage SABnzbd Usenet downloads. Use list_services to see available services, search_api to find endpoints, and call_api to make requests."),
-	)
+	s := server.NewMCPServer("navigatorr", "1.0.0", opts...)
+
+	// Register all tools, then keep them in step with the config file
+	reload := newReloader(path, s, authn)
+	reload.install(rt)
+	go reload.watch(ctx)
 
-	// Register all tools
-	tools.RegisterAll(s, cfg, registry, specStore, txClient, qbClient, sabClient)
+	if *transport == "http" {
+		if err := serveHTTP(s, *listen, authn); err != nil {
+			fmt.Fprintf(os.Stderr, "server error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	internal.Logf("starting navigatorr MCP server (stdio)")
 
@@ -90,3 +93,22 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// pipedStdin returns stdin when something was piped into it, and nil when it
+// is a terminal so `navigatorr tool` does not wait for input nobody will type.
+func pipedStdin() io.Reader {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice != 0 {
+		return nil
+	}
+	return os.Stdin
+}
+
+// terminalStdin returns stdin when it is a terminal, for confirmation prompts,
+// and nil otherwise.
+func terminalStdin() io.Reader {
+	if pipedStdin() != nil {
+		return nil
+	}
+	return os.Stdin
+}
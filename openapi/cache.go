@@ -43,6 +43,17 @@ func (c *Cache) Get(url string) []byte {
 	return data
 }
 
+// Age reports how old the cached copy of url is, and whether one exists and is
+// still fresh enough for Get to return it.
+func (c *Cache) Age(url string) (time.Duration, bool) {
+	info, err := os.Stat(c.cacheFile(url))
+	if err != nil {
+		return 0, false
+	}
+	age := time.Since(info.ModTime())
+	return age, age <= cacheTTL
+}
+
 // Put stores data in the cache. The write goes to a temp file and is renamed
 // into place, so an interrupted write cannot leave a truncated spec that the
 // next 24 hours of cache hits would fail to parse.
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"time"
+
+	"github.com/jakenesler/navigatorr/internal"
 )
 
 var fetchClient = &http.Client{Timeout: 30 * time.Second}
@@ -42,7 +43,7 @@ func Fetch(ctx context.Context, url string, cache *Cache) ([]byte, error) {
 	// Cache to disk
 	if err := cache.Put(url, data); err != nil {
 		// Non-fatal, just log
-		fmt.Fprintf(os.Stderr, "warning: failed to cache spec: %v\n", err)
+		internal.ErrorContextf(ctx, "failed to cache spec: %v", err)
 	}
 
 	return data, nil
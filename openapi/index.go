@@ -4,12 +4,23 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
 )
 
 // Index holds parsed endpoint data for a single service.
 type Index struct {
 	Service   string
 	Endpoints map[string]map[string]*EndpointDetail // path -> method -> detail
+	Sensitive map[string]bool                       // property names the spec marks as secret
+
+	doc *openapi3.T // the parsed spec, kept for request validation
+}
+
+// view returns idx relabelled for another service sharing the same spec. The
+// endpoint map and secret names are shared, not copied.
+func (idx *Index) view(service string) *Index {
+	return &Index{Service: service, Endpoints: idx.Endpoints, Sensitive: idx.Sensitive, doc: idx.doc}
 }
 
 // Count returns the total number of endpoints.
@@ -95,7 +106,7 @@ func (idx *Index) GetDetail(path, method string) (*EndpointDetail, error) {
 	}
 
 	if detail, ok := methods[method]; ok {
-		return detail, nil
+		return idx.relabel(detail), nil
 	}
 
 	// Fall back to the first method by name, so repeated calls agree.
@@ -107,7 +118,69 @@ func (idx *Index) GetDetail(path, method string) (*EndpointDetail, error) {
 		return nil, fmt.Errorf("method %s not found for %s", method, path)
 	}
 	sort.Strings(names)
-	return methods[names[0]], nil
+	return idx.relabel(methods[names[0]]), nil
+}
+
+// Match returns the endpoint a concrete request path resolves to, matching
+// templated segments such as {id} against any value. Unlike GetDetail it does
+// not guess: a path with no endpoint, or an endpoint without the method,
+// matches nothing. When several templates fit, the one with the most literal
+// segments wins, so /series/lookup beats /series/{id}.
+func (idx *Index) Match(path, method string) (*EndpointDetail, bool) {
+	if detail, ok := idx.Endpoints[path][method]; ok {
+		return idx.relabel(detail), true
+	}
+	want := strings.Split(strings.Trim(path, "/"), "/")
+	var best *EndpointDetail
+	bestLiterals := -1
+	for p, methods := range idx.Endpoints {
+		detail, ok := methods[method]
+		if !ok {
+			continue
+		}
+		literals, ok := matchTemplate(strings.Split(strings.Trim(p, "/"), "/"), want)
+		if !ok {
+			continue
+		}
+		if literals > bestLiterals || (literals == bestLiterals && p < best.Path) {
+			best, bestLiterals = detail, literals
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	return idx.relabel(best), true
+}
+
+// matchTemplate reports whether the segments of a concrete path fit a path
+// template, and how many of the template's segments are literal.
+func matchTemplate(template, path []string) (int, bool) {
+	if len(template) != len(path) {
+		return 0, false
+	}
+	literals := 0
+	for i, seg := range template {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			continue
+		}
+		if !strings.EqualFold(seg, path[i]) {
+			return 0, false
+		}
+		literals++
+	}
+	return literals, true
+}
+
+// relabel returns detail attributed to this index's service. Details are
+// shared between services using the same spec, so they carry the service type
+// they were parsed under rather than the instance name.
+func (idx *Index) relabel(detail *EndpointDetail) *EndpointDetail {
+	if detail.Service == idx.Service {
+		return detail
+	}
+	d := *detail
+	d.Service = idx.Service
+	return &d
 }
 
 // sortSummaries orders results by path then method so tool output is stable
@@ -2,9 +2,15 @@ package openapi
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/jakenesler/navigatorr/config"
 )
 
 // A parameter schema with no "type" is legal OpenAPI and appears in real specs
@@ -51,6 +57,34 @@ func TestParseTypelessParameterSchema(t *testing.T) {
 	}
 }
 
+func TestParseCollectsSecretFields(t *testing.T) {
+	const spec = `{
+  "openapi": "3.0.0",
+  "info": {"title": "t", "version": "1"},
+  "paths": {
+    "/settings": {
+      "get": {
+        "responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {
+          "type": "object",
+          "properties": {"pin": {"type": "string", "x-sensitive": true}, "host": {"type": "string"}}
+        }}}}}
+      }
+    }
+  },
+  "components": {"schemas": {"Login": {"type": "object", "properties": {
+    "user": {"type": "string"},
+    "passphrase": {"type": "string", "format": "password"}
+  }}}}
+}`
+	idx, err := Parse(context.Background(), "demo", []byte(spec))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !idx.Sensitive["pin"] || !idx.Sensitive["passphrase"] || idx.Sensitive["host"] || idx.Sensitive["user"] {
+		t.Errorf("Sensitive = %v, want pin and passphrase", idx.Sensitive)
+	}
+}
+
 func testIndex() *Index {
 	mk := func(method, path string) *EndpointDetail {
 		return &EndpointDetail{Service: "sonarr", Method: method, Path: path, Summary: method + " " + path}
@@ -105,6 +139,30 @@ func TestGetDetailIsDeterministic(t *testing.T) {
 	}
 }
 
+func TestMatchResolvesTemplates(t *testing.T) {
+	idx := testIndex()
+	tests := []struct {
+		path, method string
+		want         string // empty for no match
+	}{
+		{"/series", "GET", "/series"},
+		{"/series/12", "PUT", "/series/{id}"},
+		{"/series/lookup", "GET", "/series/lookup"}, // literal beats template
+		{"/series/12", "DELETE", ""},                // no such method
+		{"/series/12/extra", "GET", ""},
+		{"/episode", "GET", ""},
+	}
+	for _, tt := range tests {
+		got, ok := idx.Match(tt.path, tt.method)
+		switch {
+		case tt.want == "" && ok:
+			t.Errorf("Match(%q, %q) = %s, want no match", tt.path, tt.method, got.Path)
+		case tt.want != "" && (!ok || got.Path != tt.want):
+			t.Errorf("Match(%q, %q) = %v, %v; want %s", tt.path, tt.method, got, ok, tt.want)
+		}
+	}
+}
+
 // Search and Filter walk maps too; their output order must be stable or the
 // same query renders differently every call.
 func TestSearchAndFilterAreOrdered(t *testing.T) {
@@ -197,3 +255,214 @@ func TestCacheRoundTripAndAtomicity(t *testing.T) {
 		t.Error("sanity check failed")
 	}
 }
+
+// Two instances of one service type point at the same spec. It should be
+// fetched and parsed once, while each instance still reports its own name.
+func TestStoreSharesSpecAcrossInstances(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const spec = `{"openapi":"3.0.0","info":{"title":"t","version":"1"},
+  "paths":{"/series":{"get":{"summary":"list","responses":{"200":{"description":"ok"}}}}}}`
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches++
+		w.Write([]byte(spec))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr":      {Type: "sonarr", OpenAPIURL: srv.URL},
+		"sonarr4k":    {Type: "sonarr", OpenAPIURL: srv.URL},
+		"sonarranime": {Type: "sonarr", OpenAPIURL: srv.URL},
+	}}
+	store := NewStore(cfg)
+	store.LoadAll(context.Background())
+
+	if fetches != 1 {
+		t.Errorf("spec fetched %d times, want 1", fetches)
+	}
+	for name := range cfg.Services {
+		idx := store.GetIndex(name)
+		if idx == nil {
+			t.Fatalf("%s has no index", name)
+		}
+		if idx.Service != name {
+			t.Errorf("index for %s is labelled %q", name, idx.Service)
+		}
+		detail, err := idx.GetDetail("/series", "GET")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detail.Service != name {
+			t.Errorf("detail for %s is labelled %q", name, detail.Service)
+		}
+	}
+	a, b := store.GetIndex("sonarr"), store.GetIndex("sonarr4k")
+	if a.Endpoints["/series"]["GET"] != b.Endpoints["/series"]["GET"] {
+		t.Error("instances should share one parsed index")
+	}
+
+	results := store.Search("series", "")
+	if len(results) != 3 {
+		t.Fatalf("search returned %d results, want one per instance", len(results))
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	const spec = `{
+  "openapi": "3.0.0",
+  "info": {"title": "t", "version": "1"},
+  "paths": {
+    "/api/v3/series/lookup": {
+      "get": {
+        "parameters": [{"name": "term", "in": "query", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "ok"}}
+      }
+    },
+    "/api/v3/series/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}],
+      "get": {"summary": "Get series", "responses": {"200": {"description": "ok"}}},
+      "put": {
+        "summary": "Update series",
+        "requestBody": {"content": {"application/json": {"schema": {
+          "type": "object",
+          "required": ["title"],
+          "properties": {
+            "title": {"type": "string"},
+            "monitored": {"type": "boolean"},
+            "seriesType": {"type": "string", "enum": ["standard", "daily", "anime"]}
+          }
+        }}}},
+        "responses": {"200": {"description": "ok"}}
+      }
+    },
+    "/api/v3/episode": {"get": {"summary": "List episodes", "responses": {"200": {"description": "ok"}}}}
+  }
+}`
+	idx, err := Parse(context.Background(), "sonarr", []byte(spec))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	validate := func(method, path, body string) (*EndpointDetail, error) {
+		req := httptest.NewRequest(method, "http://sonarr:8989/api/v3"+path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return idx.Validate(context.Background(), req, "/api/v3"+strings.SplitN(path, "?", 2)[0])
+	}
+
+	if detail, err := validate("PUT", "/series/12", `{"title":"Firefly","seriesType":"anime"}`); err != nil || detail.Summary != "Update series" {
+		t.Errorf("valid PUT: %v, %v", detail, err)
+	}
+	if _, err := validate("GET", "/series/lookup?term=firefly", ""); err != nil {
+		t.Errorf("valid lookup: %v", err)
+	}
+
+	tests := []struct {
+		method, path, body string
+		want               []string
+	}{
+		{"GET", "/series/lookup", "", []string{`query parameter "term" is required`}},
+		{"GET", "/series/abc", "", []string{`path parameter "id"`}},
+		{"PUT", "/series/12", `{"monitored":"yes","seriesType":"weekly"}`, []string{
+			"body field title", "body field monitored", "body field seriesType", `allowed values ["standard","daily","anime"]`,
+		}},
+		{"DELETE", "/series/12", "", []string{"it supports GET, PUT"}},
+		{"GET", "/seires/12", "", []string{"nearest:", "GET /api/v3/series/{id} (Get series)"}},
+	}
+	for _, tt := range tests {
+		_, err := validate(tt.method, tt.path, tt.body)
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s %s: err = %v, want a ValidationError", tt.method, tt.path, err)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s %s: error is missing %q:\n%s", tt.method, tt.path, want, err)
+			}
+		}
+	}
+}
+
+func TestNearestIgnoresIDs(t *testing.T) {
+	got := testIndex().Nearest("/series/12/", 1)
+	if len(got) != 2 || got[0].Path != "/series/{id}" {
+		t.Errorf("Nearest = %+v, want the /series/{id} methods", got)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	tests := []struct {
+		template string
+		params   map[string]any
+		want     string // empty when an error is expected
+	}{
+		{"/series/{id}", map[string]any{"id": float64(12)}, "/series/12"},
+		{"/series/{id}/episodes/{episodeId}", map[string]any{"id": "1", "episodeId": "2"}, "/series/1/episodes/2"},
+		{"/tag/{label}", map[string]any{"label": "a/b c"}, "/tag/a%2Fb%20c"},
+		{"/series", nil, "/series"},
+		{"/series/{id}", nil, ""},
+		{"/series/{id}", map[string]any{"id": "1", "seriesId": "1"}, ""},
+		{"/series/{id}", map[string]any{"id": []any{1.0}}, ""},
+	}
+	for _, tt := range tests {
+		got, err := ExpandPath(tt.template, tt.params)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ExpandPath(%q, %v) = %q, want an error", tt.template, tt.params, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ExpandPath(%q, %v) = %q, %v; want %q", tt.template, tt.params, got, err, tt.want)
+		}
+	}
+}
+
+func TestEncodeQuery(t *testing.T) {
+	const spec = `{
+  "openapi": "3.0.0",
+  "info": {"title": "t", "version": "1"},
+  "paths": {
+    "/queue": {
+      "parameters": [{"name": "status", "in": "query", "explode": false, "schema": {"type": "array", "items": {"type": "string"}}}],
+      "get": {
+        "parameters": [
+          {"name": "tags", "in": "query", "style": "pipeDelimited", "explode": false, "schema": {"type": "array", "items": {"type": "integer"}}},
+          {"name": "filter", "in": "query", "style": "deepObject", "explode": true, "schema": {"type": "object"}}
+        ],
+        "responses": {"200": {"description": "ok"}}
+      }
+    }
+  }
+}`
+	idx, err := Parse(context.Background(), "sonarr", []byte(spec))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	detail, _ := idx.Match("/queue", "GET")
+
+	tests := []struct {
+		name   string
+		detail *EndpointDetail
+		args   map[string]any
+		want   string
+	}{
+		{"default form explodes", nil, map[string]any{"episodeIds": []any{1.0, 2.0}, "page": 2.0}, "episodeIds=1&episodeIds=2&page=2"},
+		{"large ids keep their digits", nil, map[string]any{"id": 12345678.0}, "id=12345678"},
+		{"explode false from the path item", detail, map[string]any{"status": []any{"queued", "paused"}}, "status=queued%2Cpaused"},
+		{"pipe delimited", detail, map[string]any{"tags": []any{1.0, 2.0}}, "tags=1%7C2"},
+		{"deep object", detail, map[string]any{"filter": map[string]any{"a": "x", "b": true}}, "filter%5Ba%5D=x&filter%5Bb%5D=true"},
+		{"unknown params use the default", detail, map[string]any{"ids": []any{"a", "b"}}, "ids=a&ids=b"},
+	}
+	for _, tt := range tests {
+		got, err := EncodeQuery(tt.detail, tt.args)
+		if err != nil || got.Encode() != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got.Encode(), err, tt.want)
+		}
+	}
+	if _, err := EncodeQuery(nil, map[string]any{"ids": []any{[]any{1.0}}}); err == nil {
+		t.Error("nested arrays should be rejected")
+	}
+}
@@ -3,10 +3,10 @@ package openapi
 import (
 	"context"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/jakenesler/navigatorr/internal"
 )
 
 // Parse loads an OpenAPI spec from raw bytes and builds an Index.
@@ -22,7 +22,7 @@ func Parse(ctx context.Context, service string, data []byte) (*Index, error) {
 	// Validate (non-fatal — some specs have minor issues)
 	if err := doc.Validate(ctx); err != nil {
 		// Log but don't fail
-		fmt.Fprintf(os.Stderr, "warning: spec validation for %s: %v\n", service, err)
+		internal.LogContextf(ctx, "WARNING: spec validation for %s: %v", service, err)
 	}
 
 	return buildIndex(service, doc), nil
@@ -50,6 +50,8 @@ func buildIndex(service string, doc *openapi3.T) *Index {
 				Description: op.Description,
 				Tags:        op.Tags,
 				Responses:   make(map[string]string),
+				item:        pathItem,
+				op:          op,
 			}
 
 			// Parameters
@@ -102,9 +104,81 @@ func buildIndex(service string, doc *openapi3.T) *Index {
 		}
 	}
 
+	idx.Sensitive = secretFields(doc)
+	idx.doc = doc
 	return idx
 }
 
+// secretFields collects the names of properties the spec marks as secret,
+// with format: password or x-sensitive: true, anywhere in its schemas.
+func secretFields(doc *openapi3.T) map[string]bool {
+	names := make(map[string]bool)
+	seen := make(map[*openapi3.Schema]bool)
+	var walk func(ref *openapi3.SchemaRef)
+	walk = func(ref *openapi3.SchemaRef) {
+		if ref == nil || ref.Value == nil || seen[ref.Value] {
+			return
+		}
+		schema := ref.Value
+		seen[schema] = true
+		for name, prop := range schema.Properties {
+			if prop.Value != nil && (prop.Value.Format == "password" || isTrue(prop.Value.Extensions["x-sensitive"])) {
+				names[name] = true
+			}
+			walk(prop)
+		}
+		walk(schema.Items)
+		if schema.AdditionalProperties.Schema != nil {
+			walk(schema.AdditionalProperties.Schema)
+		}
+		for _, group := range []openapi3.SchemaRefs{schema.AllOf, schema.AnyOf, schema.OneOf} {
+			for _, sub := range group {
+				walk(sub)
+			}
+		}
+	}
+
+	if doc.Components != nil {
+		for _, ref := range doc.Components.Schemas {
+			walk(ref)
+		}
+	}
+	for _, pathItem := range doc.Paths.Map() {
+		for _, op := range pathItem.Operations() {
+			if op == nil {
+				continue
+			}
+			if op.RequestBody != nil && op.RequestBody.Value != nil {
+				for _, mt := range op.RequestBody.Value.Content {
+					walk(mt.Schema)
+				}
+			}
+			if op.Responses == nil {
+				continue
+			}
+			for _, resp := range op.Responses.Map() {
+				if resp.Value == nil {
+					continue
+				}
+				for _, mt := range resp.Value.Content {
+					walk(mt.Schema)
+				}
+			}
+		}
+	}
+	return names
+}
+
+func isTrue(v any) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case string:
+		return strings.EqualFold(t, "true")
+	}
+	return false
+}
+
 // flattenSchema extracts property names and types from a schema.
 func flattenSchema(schema *openapi3.Schema) map[string]any {
 	if schema == nil || len(schema.Properties) == 0 {
@@ -0,0 +1,130 @@
+package openapi
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// EncodeQuery turns decoded JSON query arguments into URL values. Arrays and
+// objects are spelled the way the operation declares each parameter's style
+// and explode, so {"episodeIds": [1, 2]} becomes episodeIds=1&episodeIds=2
+// under the default form style and episodeIds=1,2 with explode: false.
+// Parameters the spec does not describe, and any parameter when detail is
+// nil, use the OpenAPI default of form style, exploded.
+func EncodeQuery(detail *EndpointDetail, args map[string]any) (url.Values, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := url.Values{}
+	for _, name := range names {
+		sm := &openapi3.SerializationMethod{Style: openapi3.SerializationForm, Explode: true}
+		if p := detail.queryParameter(name); p != nil {
+			if m, err := p.SerializationMethod(); err == nil {
+				sm = m
+			}
+		}
+		if err := encodeParam(values, name, args[name], sm); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// queryParameter finds a query parameter by name, letting the operation
+// override the path item as OpenAPI specifies.
+func (d *EndpointDetail) queryParameter(name string) *openapi3.Parameter {
+	if d == nil || d.op == nil {
+		return nil
+	}
+	if p := d.op.Parameters.GetByInAndName(openapi3.ParameterInQuery, name); p != nil {
+		return p
+	}
+	if d.item != nil {
+		return d.item.Parameters.GetByInAndName(openapi3.ParameterInQuery, name)
+	}
+	return nil
+}
+
+func encodeParam(values url.Values, name string, v any, sm *openapi3.SerializationMethod) error {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		items := make([]string, len(v))
+		for i, el := range v {
+			s, ok := FormatScalar(el)
+			if !ok {
+				return fmt.Errorf("query %s: array items must be strings, numbers or booleans", name)
+			}
+			items[i] = s
+		}
+		if sm.Explode {
+			values[name] = append(values[name], items...)
+			return nil
+		}
+		sep := ","
+		switch sm.Style {
+		case openapi3.SerializationSpaceDelimited:
+			sep = " "
+		case openapi3.SerializationPipeDelimited:
+			sep = "|"
+		}
+		values.Add(name, strings.Join(items, sep))
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var pairs []string
+		for _, k := range keys {
+			s, ok := FormatScalar(v[k])
+			if !ok {
+				return fmt.Errorf("query %s.%s: object values must be strings, numbers or booleans", name, k)
+			}
+			switch {
+			case sm.Style == openapi3.SerializationDeepObject:
+				values.Add(name+"["+k+"]", s)
+			case sm.Explode:
+				values.Add(k, s)
+			default:
+				pairs = append(pairs, k, s)
+			}
+		}
+		if len(pairs) > 0 {
+			values.Add(name, strings.Join(pairs, ","))
+		}
+	default:
+		s, ok := FormatScalar(v)
+		if !ok {
+			return fmt.Errorf("query %s: unsupported value %v", name, v)
+		}
+		values.Add(name, s)
+	}
+	return nil
+}
+
+// FormatScalar formats a decoded JSON scalar for a URL. Numbers print without
+// an exponent so ids survive as written.
+func FormatScalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
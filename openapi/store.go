@@ -6,7 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jakenesler/navigatorr/config"
 	"github.com/jakenesler/navigatorr/internal"
@@ -17,6 +19,7 @@ type Store struct {
 	cfg     *config.Config
 	cache   *Cache
 	indices map[string]*Index
+	errs    map[string]error // last load error per service
 	mu      sync.RWMutex
 }
 
@@ -29,42 +32,109 @@ func NewStore(cfg *config.Config) *Store {
 		cfg:     cfg,
 		cache:   NewCache(cacheDir),
 		indices: make(map[string]*Index),
+		errs:    make(map[string]error),
 	}
 }
 
-// LoadAll fetches and parses specs for all configured services.
+// LoadAll fetches and parses specs for all configured services. Services that
+// share a spec URL, such as two Sonarr instances, share one parsed index.
 func (s *Store) LoadAll(ctx context.Context) {
-	for name, svc := range s.cfg.Services {
-		if svc.OpenAPIURL == "" {
+	for _, url := range s.specURLs() {
+		names := s.servicesFor(url)
+		if err := s.load(ctx, url); err != nil {
+			internal.ErrorContextf(ctx, "loading spec for %s: %v", strings.Join(names, ", "), err)
 			continue
 		}
-		if err := s.load(ctx, name, svc.OpenAPIURL); err != nil {
-			internal.Errorf("loading spec for %s: %v", name, err)
-		} else {
-			idx := s.GetIndex(name)
-			if idx != nil {
-				internal.Logf("loaded %s: %d endpoints", name, idx.Count())
+		for _, name := range names {
+			if idx := s.GetIndex(name); idx != nil {
+				internal.LogContextf(ctx, "loaded %s: %d endpoints", name, idx.Count())
 			}
 		}
 	}
 }
 
-func (s *Store) load(ctx context.Context, name, url string) error {
-	data, err := Fetch(ctx, url, s.cache)
-	if err != nil {
-		return err
+// specURLs returns the distinct spec URLs across configured services, sorted.
+func (s *Store) specURLs() []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, svc := range s.cfg.Services {
+		if svc.OpenAPIURL != "" && !seen[svc.OpenAPIURL] {
+			seen[svc.OpenAPIURL] = true
+			urls = append(urls, svc.OpenAPIURL)
+		}
 	}
+	sort.Strings(urls)
+	return urls
+}
 
-	idx, err := Parse(ctx, name, data)
-	if err != nil {
-		return err
+// servicesFor returns the names of the services using a spec URL, sorted.
+func (s *Store) servicesFor(url string) []string {
+	var names []string
+	for name, svc := range s.cfg.Services {
+		if svc.OpenAPIURL == url {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// load fetches and parses one spec and installs it for every service using
+// it. A failure is remembered for Status; any index from an earlier successful
+// load stays in place.
+func (s *Store) load(ctx context.Context, url string) error {
+	names := s.servicesFor(url)
+	if len(names) == 0 {
+		return nil
 	}
 
+	idx, err := s.fetchAndParse(ctx, url, names[0])
+
 	s.mu.Lock()
-	s.indices[name] = idx
+	for _, name := range names {
+		s.errs[name] = err
+		if err == nil {
+			s.indices[name] = idx.view(name)
+		}
+	}
 	s.mu.Unlock()
 
-	return nil
+	return err
+}
+
+func (s *Store) fetchAndParse(ctx context.Context, url, name string) (*Index, error) {
+	data, err := Fetch(ctx, url, s.cache)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(ctx, s.cfg.Services[name].ServiceType(name), data)
+}
+
+// SpecStatus describes the spec state of one service.
+type SpecStatus struct {
+	URL       string        // empty when the service has no spec
+	Endpoints int           // zero when no index is loaded
+	CacheAge  time.Duration // age of the on-disk copy, zero when not cached
+	Cached    bool
+	Err       error // last fetch or parse error
+}
+
+// Status reports whether a service's spec was fetched, parsed and cached.
+func (s *Store) Status(name string) SpecStatus {
+	svc := s.cfg.Services[name]
+	st := SpecStatus{URL: svc.OpenAPIURL}
+	if st.URL == "" {
+		return st
+	}
+	st.CacheAge, st.Cached = s.cache.Age(st.URL)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if idx := s.indices[name]; idx != nil {
+		st.Endpoints = idx.Count()
+	}
+	st.Err = s.errs[name]
+	return st
 }
 
 // GetIndex returns the index for a service.
@@ -95,7 +165,8 @@ func (s *Store) Search(query, serviceName string) []EndpointSummary {
 	return results
 }
 
-// Refresh re-fetches the spec for a specific service.
+// Refresh re-fetches the spec for a specific service, and with it every other
+// service sharing the same spec.
 func (s *Store) Refresh(ctx context.Context, name string) error {
 	svc, ok := s.cfg.Services[name]
 	if !ok {
@@ -108,19 +179,19 @@ func (s *Store) Refresh(ctx context.Context, name string) error {
 	// Invalidate cache
 	s.cache.Invalidate(svc.OpenAPIURL)
 
-	return s.load(ctx, name, svc.OpenAPIURL)
+	return s.load(ctx, svc.OpenAPIURL)
 }
 
-// RefreshAll re-fetches specs for all services.
+// RefreshAll re-fetches specs for all services. A failed shared spec is
+// reported against each service that uses it.
 func (s *Store) RefreshAll(ctx context.Context) map[string]error {
 	errors := make(map[string]error)
-	for name, svc := range s.cfg.Services {
-		if svc.OpenAPIURL == "" {
-			continue
-		}
-		s.cache.Invalidate(svc.OpenAPIURL)
-		if err := s.load(ctx, name, svc.OpenAPIURL); err != nil {
-			errors[name] = err
+	for _, url := range s.specURLs() {
+		s.cache.Invalidate(url)
+		if err := s.load(ctx, url); err != nil {
+			for _, name := range s.servicesFor(url) {
+				errors[name] = err
+			}
 		}
 	}
 	if len(errors) == 0 {
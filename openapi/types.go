@@ -1,5 +1,7 @@
 package openapi
 
+import "github.com/getkin/kin-openapi/openapi3"
+
 // EndpointSummary is a compact listing entry.
 type EndpointSummary struct {
 	Service string `json:"service"`
@@ -20,6 +22,11 @@ type EndpointDetail struct {
 	Parameters  []ParameterInfo   `json:"parameters,omitempty"`
 	RequestBody *SchemaInfo       `json:"request_body,omitempty"`
 	Responses   map[string]string `json:"responses,omitempty"`
+
+	// The operation behind the detail, for request validation. Nil for
+	// details not parsed from a spec.
+	item *openapi3.PathItem
+	op   *openapi3.Operation
 }
 
 // ParameterInfo describes a single parameter.
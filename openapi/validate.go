@@ -0,0 +1,292 @@
+package openapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// ValidationError explains why a request does not fit the spec. Exactly one
+// of its lists is set: Problems when the endpoint exists but the request is
+// malformed, Supported when the path exists without the method, and Nearest
+// when no path matches at all.
+type ValidationError struct {
+	Method    string
+	Path      string
+	Problems  []string          // missing, mistyped or out-of-range values
+	Supported []string          // methods the path does define
+	Nearest   []EndpointSummary // closest endpoints to an unknown path
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	switch {
+	case len(e.Problems) > 0:
+		fmt.Fprintf(&b, "%s %s does not match the spec:", e.Method, e.Path)
+		for _, p := range e.Problems {
+			b.WriteString("\n- " + p)
+		}
+	case len(e.Supported) > 0:
+		fmt.Fprintf(&b, "%s is not defined for %s; it supports %s", e.Method, e.Path, strings.Join(e.Supported, ", "))
+	default:
+		fmt.Fprintf(&b, "no endpoint in the spec matches %s", e.Path)
+		if len(e.Nearest) > 0 {
+			b.WriteString("; nearest:")
+		}
+		for _, s := range e.Nearest {
+			fmt.Fprintf(&b, "\n- %s %s", s.Method, s.Path)
+			if s.Summary != "" {
+				b.WriteString(" (" + s.Summary + ")")
+			}
+		}
+	}
+	return b.String()
+}
+
+// Validate resolves req to the operation it calls and checks it against the
+// spec: required path and query parameters, parameter types, and the body
+// schema. paths are the spellings of the request path to try, in order, since
+// callers may or may not include the API version prefix the spec uses. The
+// detail is returned whenever the endpoint resolves, even if the request then
+// fails validation; the error is always a *ValidationError.
+func (idx *Index) Validate(ctx context.Context, req *http.Request, paths ...string) (*EndpointDetail, error) {
+	for _, p := range paths {
+		if detail, ok := idx.Match(p, req.Method); ok {
+			if problems := idx.check(ctx, req, detail, p); len(problems) > 0 {
+				return detail, &ValidationError{Method: req.Method, Path: p, Problems: problems}
+			}
+			return detail, nil
+		}
+	}
+	for _, p := range paths {
+		if methods := idx.methodsFor(p); len(methods) > 0 {
+			return nil, &ValidationError{Method: req.Method, Path: p, Supported: methods}
+		}
+	}
+	return nil, &ValidationError{Method: req.Method, Path: paths[0], Nearest: idx.Nearest(paths[0], 5)}
+}
+
+// check runs kin-openapi's request validator over one resolved operation.
+// Authentication is the service client's business, so security requirements
+// are not checked, and the request is left as it was rather than filled in
+// with the spec's defaults.
+func (idx *Index) check(ctx context.Context, req *http.Request, detail *EndpointDetail, path string) []string {
+	if detail.op == nil || idx.doc == nil {
+		return nil
+	}
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: PathParams(detail.Path, path),
+		Route: &routers.Route{
+			Spec:      idx.doc,
+			Path:      detail.Path,
+			PathItem:  detail.item,
+			Method:    detail.Method,
+			Operation: detail.op,
+		},
+		Options: &openapi3filter.Options{
+			MultiError:          true,
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			SkipSettingDefaults: true,
+		},
+	}
+	return describeProblems(openapi3filter.ValidateRequest(ctx, input), "")
+}
+
+// PathParams pairs each {name} in a path template with the matching segment
+// of a concrete path. The path is assumed to fit the template.
+func PathParams(template, path string) map[string]string {
+	tmpl := strings.Split(strings.Trim(template, "/"), "/")
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	params := map[string]string{}
+	for i, seg := range tmpl {
+		if i < len(segs) && strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params[seg[1:len(seg)-1]] = segs[i]
+		}
+	}
+	return params
+}
+
+// ExpandPath fills each {name} in a path template from decoded JSON params,
+// escaping the values so a title or path cannot add segments of its own.
+// Every placeholder must have a scalar value and every value a placeholder.
+func ExpandPath(template string, params map[string]any) (string, error) {
+	segs := strings.Split(template, "/")
+	used := map[string]bool{}
+	var missing []string
+	for i, seg := range segs {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		name := seg[1 : len(seg)-1]
+		raw, ok := params[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		value, ok := FormatScalar(raw)
+		if !ok {
+			return "", fmt.Errorf("path_params %s must be a string, number or boolean", name)
+		}
+		segs[i] = url.PathEscape(value)
+		used[name] = true
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("path_params is missing %s for %s", strings.Join(missing, ", "), template)
+	}
+	var unused []string
+	for name := range params {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", fmt.Errorf("path_params has %s, which %s does not use", strings.Join(unused, ", "), template)
+	}
+	return strings.Join(segs, "/"), nil
+}
+
+// describeProblems flattens a validation error into one line per field,
+// naming where the field lives and, for enums, the values it accepts.
+func describeProblems(err error, where string) []string {
+	if err == nil {
+		return nil
+	}
+	// Match on the concrete type: MultiError and RequestError both unwrap,
+	// so errors.As would find a nested error before the one holding context.
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var out []string
+		for _, inner := range e {
+			out = append(out, describeProblems(inner, where)...)
+		}
+		return out
+	case *openapi3filter.RequestError:
+		where = "body"
+		if p := e.Parameter; p != nil {
+			where = fmt.Sprintf("%s parameter %q", p.In, p.Name)
+		}
+		switch {
+		case e.Err == nil:
+			return []string{where + ": " + e.Reason}
+		case errors.Is(e.Err, openapi3filter.ErrInvalidRequired):
+			return []string{where + " is required"}
+		}
+		return describeProblems(e.Err, where)
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 {
+			where += " field " + strings.Join(pointer, ".")
+		}
+		if e.SchemaField == "required" {
+			return []string{where + " is required"}
+		}
+		line := where + ": " + e.Reason
+		// An enum mismatch already lists the values; a wrong type does not.
+		if s := e.Schema; s != nil && len(s.Enum) > 0 && e.SchemaField != "enum" {
+			allowed := make([]string, len(s.Enum))
+			for i, v := range s.Enum {
+				allowed[i] = fmt.Sprint(v)
+			}
+			line += " (allowed: " + strings.Join(allowed, ", ") + ")"
+		}
+		return []string{line}
+	}
+	if where == "" {
+		return []string{err.Error()}
+	}
+	return []string{where + ": " + err.Error()}
+}
+
+// methodsFor lists the methods defined on whichever templates fit path.
+func (idx *Index) methodsFor(path string) []string {
+	want := strings.Split(strings.Trim(path, "/"), "/")
+	seen := map[string]bool{}
+	for p, methods := range idx.Endpoints {
+		if _, ok := matchTemplate(strings.Split(strings.Trim(p, "/"), "/"), want); !ok {
+			continue
+		}
+		for m := range methods {
+			seen[m] = true
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for m := range seen {
+		out = append(out, m)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Nearest returns the endpoints on the n paths closest to path by edit
+// distance. Templated and numeric segments compare equal, so /series/12/epsiode
+// still finds /series/{id}/episode.
+func (idx *Index) Nearest(path string, n int) []EndpointSummary {
+	want := pathShape(path)
+	type candidate struct {
+		path string
+		dist int
+	}
+	candidates := make([]candidate, 0, len(idx.Endpoints))
+	for p := range idx.Endpoints {
+		candidates = append(candidates, candidate{p, editDistance(want, pathShape(p))})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].dist != candidates[j].dist {
+			return candidates[i].dist < candidates[j].dist
+		}
+		return candidates[i].path < candidates[j].path
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	var out []EndpointSummary
+	for _, c := range candidates {
+		var results []EndpointSummary
+		for m, detail := range idx.Endpoints[c.path] {
+			results = append(results, EndpointSummary{Service: idx.Service, Method: m, Path: c.path, Summary: detail.Summary})
+		}
+		out = append(out, sortSummaries(results)...)
+	}
+	return out
+}
+
+// pathShape lower-cases a path and replaces its variable parts with {}.
+func pathShape(path string) string {
+	segs := strings.Split(strings.Trim(strings.ToLower(path), "/"), "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, "{") || (seg != "" && strings.Trim(seg, "0123456789") == "") {
+			segs[i] = "{}"
+		}
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
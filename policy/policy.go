@@ -0,0 +1,260 @@
+// Package policy decides whether a call may run, from the allow, deny and
+// destructive rules under policy: in config.yaml.
+package policy
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/jakenesler/navigatorr/config"
+)
+
+// Effect is what a matching rule does with a call.
+type Effect string
+
+const (
+	Allow       Effect = "allow"
+	Deny        Effect = "deny"
+	Destructive Effect = "destructive" // runs only when allow_destructive is on
+)
+
+// Request describes a call. Download client actions are described in the
+// terms of their own API, so one rule syntax covers every tool.
+type Request struct {
+	Service string // instance name, e.g. "sonarr4k"
+	Type    string // service type, e.g. "sonarr"
+	Method  string
+	Path    string // relative to the API version prefix
+	// Template is the spec path template the call resolved to, such as
+	// /series/{id}, when known. A rule path matches either spelling.
+	Template string
+	Query    url.Values
+	Body     any // decoded JSON, or nil
+}
+
+// Decision is the outcome for one request.
+type Decision struct {
+	Effect Effect
+	Reason string // the matching rule's reason; empty when no rule matched
+}
+
+// Policy is a compiled rule list.
+type Policy struct {
+	rules   []rule
+	reveals []rule // calls allowed to opt out of secret masking
+}
+
+type rule struct {
+	effect   Effect
+	services map[string]bool
+	methods  map[string]bool
+	path     *regexp.Regexp
+	body     map[string]any
+	query    map[string]any
+	reason   string
+}
+
+// New compiles the configured rules followed, unless disabled, by
+// config.DefaultPolicyRules.
+func New(cfg config.PolicyConfig) *Policy {
+	rules := cfg.Rules
+	if cfg.UseDefaults() {
+		rules = append(append([]config.PolicyRule{}, rules...), config.DefaultPolicyRules...)
+	}
+
+	p := &Policy{}
+	for i, r := range rules {
+		c := rule{
+			effect:   Effect(r.Effect),
+			services: lowerSet(r.Services),
+			methods:  upperSet(r.Methods),
+			body:     r.Body,
+			query:    r.Query,
+			reason:   r.Reason,
+		}
+		if r.Path != "" {
+			c.path = compileGlob(r.Path)
+		}
+		if c.reason == "" {
+			c.reason = fmt.Sprintf("policy rule %d", i+1)
+		}
+		p.rules = append(p.rules, c)
+	}
+	for _, r := range cfg.RevealSecrets {
+		c := rule{services: lowerSet(r.Services), methods: upperSet(r.Methods), body: r.Body, query: r.Query}
+		if r.Path != "" {
+			c.path = compileGlob(r.Path)
+		}
+		p.reveals = append(p.reveals, c)
+	}
+	return p
+}
+
+// Evaluate returns the effect of the first rule matching r, or Allow.
+func (p *Policy) Evaluate(r Request) Decision {
+	if p == nil {
+		return Decision{Effect: Allow}
+	}
+	r.Method = strings.ToUpper(r.Method)
+	r.Path = cleanPath(r.Path)
+	for _, rl := range p.rules {
+		if rl.matches(r) {
+			return Decision{Effect: rl.effect, Reason: rl.reason}
+		}
+	}
+	return Decision{Effect: Allow}
+}
+
+// RevealsSecrets reports whether a reveal_secrets rule lets r return secrets
+// unmasked. Nothing is revealed unless a rule says so.
+func (p *Policy) RevealsSecrets(r Request) bool {
+	if p == nil {
+		return false
+	}
+	r.Method = strings.ToUpper(r.Method)
+	r.Path = cleanPath(r.Path)
+	for _, rl := range p.reveals {
+		if rl.matches(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rl rule) matches(r Request) bool {
+	if rl.services != nil && !rl.services[strings.ToLower(r.Service)] && !rl.services[strings.ToLower(r.Type)] {
+		return false
+	}
+	if rl.methods != nil && !rl.methods[r.Method] {
+		return false
+	}
+	if rl.path != nil && !rl.path.MatchString(r.Path) && (r.Template == "" || !rl.path.MatchString(cleanPath(r.Template))) {
+		return false
+	}
+	for field, want := range rl.body {
+		if !anyEqual(lookup(r.Body, strings.Split(field, ".")), want) {
+			return false
+		}
+	}
+	for param, want := range rl.query {
+		// Query parameters bind without regard to case as well.
+		var got []any
+		for k, vs := range r.Query {
+			if strings.EqualFold(k, param) {
+				for _, v := range vs {
+					got = append(got, v)
+				}
+			}
+		}
+		if !anyEqual(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// cleanPath drops the query string and resolves dot segments, so
+// "/series/../command" cannot slip past a rule for "/command".
+func cleanPath(p string) string {
+	p, _, _ = strings.Cut(p, "?")
+	return path.Clean("/" + p)
+}
+
+// compileGlob turns a path glob into an anchored, case-insensitive regexp —
+// the *arr apps route without regard to case. * matches within one segment,
+// /** matches zero or more whole segments.
+func compileGlob(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "/**"):
+			b.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// lookup collects the values at a dotted field path. Arrays fan out, so a
+// field inside a list of objects matches when any element has it. The *arr
+// apps bind JSON keys without regard to case, so without an exact match a key
+// differing only in case counts, and "Name" is read as name.
+func lookup(v any, parts []string) []any {
+	if arr, ok := v.([]any); ok {
+		var out []any
+		for _, el := range arr {
+			out = append(out, lookup(el, parts)...)
+		}
+		return out
+	}
+	if len(parts) == 0 {
+		return []any{v}
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if child, ok := obj[parts[0]]; ok {
+		return lookup(child, parts[1:])
+	}
+	var out []any
+	for k, child := range obj {
+		if strings.EqualFold(k, parts[0]) {
+			out = append(out, lookup(child, parts[1:])...)
+		}
+	}
+	return out
+}
+
+// anyEqual reports whether any value in got equals want, or one of want's
+// values when want is a list. Values compare as text without regard to case,
+// so YAML's true matches JSON's true and "deleteseries" matches
+// "DeleteSeries", as the *arr apps themselves accept it.
+func anyEqual(got []any, want any) bool {
+	wants, ok := want.([]any)
+	if !ok {
+		wants = []any{want}
+	}
+	for _, g := range got {
+		for _, w := range wants {
+			if strings.EqualFold(fmt.Sprint(g), fmt.Sprint(w)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lowerSet(items []string) map[string]bool {
+	if len(items) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(items))
+	for _, s := range items {
+		set[strings.ToLower(s)] = true
+	}
+	return set
+}
+
+func upperSet(items []string) map[string]bool {
+	if len(items) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(items))
+	for _, s := range items {
+		set[strings.ToUpper(s)] = true
+	}
+	return set
+}
@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/config"
+)
+
+func TestEvaluate(t *testing.T) {
+	off := false
+	custom := config.PolicyConfig{Rules: []config.PolicyRule{
+		{Effect: "allow", Services: []string{"sonarr4k"}, Methods: []string{"delete"}, Path: "/episodefile/*"},
+		{Effect: "deny", Services: []string{"radarr"}, Path: "/config/**", Reason: "config is read-only"},
+		{Effect: "destructive", Services: []string{"sonarr"}, Methods: []string{"DELETE"}, Path: "/series/*", Query: map[string]any{"deleteFiles": true}},
+	}}
+
+	tests := []struct {
+		name   string
+		cfg    config.PolicyConfig
+		req    Request
+		effect Effect
+		reason string
+	}{
+		{"GET allowed by default", config.PolicyConfig{}, Request{Service: "sonarr", Type: "sonarr", Method: "GET", Path: "/series"}, Allow, ""},
+		{"DELETE destructive by default", config.PolicyConfig{}, Request{Service: "sonarr", Type: "sonarr", Method: "delete", Path: "/series/1"}, Destructive, "DELETE requests"},
+		{"command name in a list, any case", config.PolicyConfig{}, Request{Type: "radarr", Method: "POST", Path: "/Command", Body: map[string]any{"name": "deletemovie"}}, Destructive, "commands that delete data"},
+		{"command rule keyed by type", config.PolicyConfig{}, Request{Type: "overseerr", Method: "POST", Path: "/command", Body: map[string]any{"name": "DeleteSeries"}}, Allow, ""},
+		{"body key in another case", config.PolicyConfig{}, Request{Type: "sonarr", Method: "POST", Path: "/command", Body: map[string]any{"Name": "DeleteSeries"}}, Destructive, "commands that delete data"},
+		{"deleteFiles inside an array body", config.PolicyConfig{}, Request{Type: "sonarr", Method: "PUT", Path: "/episodefile/editor", Body: []any{map[string]any{"deleteFiles": true}}}, Destructive, "requests that delete files from disk"},
+		{"/** matches zero segments", config.PolicyConfig{}, Request{Type: "sonarr", Method: "DELETE", Path: "/queue/bulk/"}, Destructive, "DELETE requests"},
+		{"/** matches several segments", config.PolicyConfig{}, Request{Type: "lidarr", Method: "POST", Path: "/queue/bulk/a/b"}, Destructive, "bulk queue removals"},
+		{"sabnzbd delete", config.PolicyConfig{}, Request{Service: "sabnzbd", Type: "sabnzbd", Method: "GET", Path: "/history/delete"}, Destructive, "deleting jobs"},
+		{"configured rule wins over defaults", custom, Request{Service: "sonarr4k", Type: "sonarr", Method: "DELETE", Path: "/episodefile/9"}, Allow, "policy rule 1"},
+		{"* stays within a segment", custom, Request{Service: "sonarr4k", Type: "sonarr", Method: "DELETE", Path: "/episodefile/9/x"}, Destructive, "DELETE requests"},
+		{"deny by path glob", custom, Request{Service: "radarr", Type: "radarr", Method: "GET", Path: "/config/host"}, Deny, "config is read-only"},
+		{"query predicate", custom, Request{Service: "sonarr", Type: "sonarr", Method: "DELETE", Path: "/series/1", Query: url.Values{"deleteFiles": {"true"}}}, Destructive, "policy rule 3"},
+		{"query key in another case", custom, Request{Service: "sonarr", Type: "sonarr", Method: "DELETE", Path: "/series/1", Query: url.Values{"DeleteFiles": {"true"}}}, Destructive, "policy rule 3"},
+		{"rule path matches the template", config.PolicyConfig{Rules: []config.PolicyRule{{Effect: "deny", Path: "/series/{id}"}}}, Request{Type: "sonarr", Method: "GET", Path: "/series/7", Template: "/series/{id}"}, Deny, "policy rule 1"},
+		{"defaults disabled", config.PolicyConfig{Defaults: &off}, Request{Type: "sonarr", Method: "DELETE", Path: "/series/1"}, Allow, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(tt.cfg).Evaluate(tt.req)
+			if d.Effect != tt.effect || d.Reason != tt.reason {
+				t.Errorf("got %s (%q), want %s (%q)", d.Effect, d.Reason, tt.effect, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRevealsSecrets(t *testing.T) {
+	p := New(config.PolicyConfig{RevealSecrets: []config.PolicyRule{
+		{Services: []string{"sonarr"}, Methods: []string{"GET"}, Path: "/downloadclient/**"},
+	}})
+	tests := []struct {
+		req  Request
+		want bool
+	}{
+		{Request{Service: "sonarr", Type: "sonarr", Method: "get", Path: "/downloadclient/3"}, true},
+		{Request{Service: "radarr", Type: "radarr", Method: "GET", Path: "/downloadclient/3"}, false},
+		{Request{Service: "sonarr", Type: "sonarr", Method: "GET", Path: "/config/host"}, false},
+	}
+	for _, tt := range tests {
+		if got := p.RevealsSecrets(tt.req); got != tt.want {
+			t.Errorf("RevealsSecrets(%+v) = %v, want %v", tt.req, got, tt.want)
+		}
+	}
+	if New(config.PolicyConfig{}).RevealsSecrets(tests[0].req) {
+		t.Error("secrets must stay masked without a reveal_secrets rule")
+	}
+}
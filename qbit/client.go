@@ -78,17 +78,9 @@ func (c *Client) do(ctx context.Context, method, path string, form url.Values) (
 			}
 		}
 
-		var body io.Reader
-		if form != nil {
-			body = strings.NewReader(form.Encode())
-		}
-
-		req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+		req, err := c.Request(ctx, method, path, form)
 		if err != nil {
-			return nil, fmt.Errorf("creating request: %w", err)
-		}
-		if form != nil {
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return nil, err
 		}
 
 		resp, err := c.http.Do(req)
@@ -118,3 +110,21 @@ func (c *Client) do(ctx context.Context, method, path string, form url.Values) (
 
 	return nil, fmt.Errorf("failed after re-login retry")
 }
+
+// Request builds a Web API request without sending it, for dry runs. The
+// session cookie is added by the client's jar when the request is sent.
+func (c *Client) Request(ctx context.Context, method, path string, form url.Values) (*http.Request, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return req, nil
+}
@@ -76,3 +76,13 @@ func (c *Client) GetTransferInfo(ctx context.Context) (*TransferInfo, error) {
 	}
 	return &info, nil
 }
+
+// Version returns the qBittorrent application version. It logs in first when
+// there is no session, so it doubles as a credentials check.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	data, err := c.do(ctx, "GET", "/api/v2/app/version", nil)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
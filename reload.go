@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/internal"
+	"github.com/jakenesler/navigatorr/openapi"
+	"github.com/jakenesler/navigatorr/qbit"
+	"github.com/jakenesler/navigatorr/sabnzbd"
+	"github.com/jakenesler/navigatorr/tools"
+	"github.com/jakenesler/navigatorr/transmission"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// pollInterval is how often the config file is checked for changes.
+const pollInterval = 2 * time.Second
+
+// runtime is everything built from one config.
+type runtime struct {
+	cfg       *config.Config
+	registry  *arrservice.Registry
+	specStore *openapi.Store
+	txClient  *transmission.Client
+	qbClient  *qbit.Client
+	sabClient *sabnzbd.Client
+	auditLog  *audit.Log
+}
+
+// buildRuntime constructs the registry, spec store and download clients for
+// cfg. Nothing in it is shared with a previous runtime, so a reload can build
+// one alongside the running server and only swap it in once it is complete.
+func buildRuntime(ctx context.Context, cfg *config.Config) *runtime {
+	rt := &runtime{
+		cfg:       cfg,
+		registry:  arrservice.NewRegistry(cfg),
+		specStore: openapi.NewStore(cfg),
+		auditLog:  audit.New(cfg.Audit),
+	}
+	rt.specStore.LoadAll(ctx)
+
+	if cfg.Transmission.URL != "" {
+		rt.txClient = transmission.NewClient(
+			cfg.Transmission.URL,
+			cfg.Transmission.Username,
+			cfg.Transmission.Password,
+		)
+		internal.Logf("transmission client configured: %s", cfg.Transmission.URL)
+	}
+
+	if cfg.QBittorrent.URL != "" {
+		rt.qbClient = qbit.NewClient(
+			cfg.QBittorrent.URL,
+			cfg.QBittorrent.Username,
+			cfg.QBittorrent.Password,
+		)
+		internal.Logf("qbittorrent client configured: %s", cfg.QBittorrent.URL)
+	}
+
+	if cfg.SABnzbd.URL != "" {
+		rt.sabClient = sabnzbd.NewClient(
+			cfg.SABnzbd.URL,
+			cfg.SABnzbd.URLBase,
+			cfg.SABnzbd.APIKey,
+		)
+		internal.Logf("sabnzbd client configured: %s", cfg.SABnzbd.URL)
+	}
+
+	return rt
+}
+
+// serverTools returns the tools RegisterAll would register for this runtime,
+// sharing state with the tools it replaces. They are collected on a scratch
+// server so the live one can take them all in a single AddTools call.
+func (rt *runtime) serverTools(state *tools.State) []server.ServerTool {
+	scratch := server.NewMCPServer("navigatorr", "scratch")
+	tools.RegisterAll(scratch, state, rt.cfg, rt.registry, rt.specStore, rt.txClient, rt.qbClient, rt.sabClient, rt.auditLog)
+
+	all := scratch.ListTools()
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]server.ServerTool, len(names))
+	for i, name := range names {
+		list[i] = *all[name]
+	}
+	return list
+}
+
+// reloader rebuilds the runtime when the config file changes or on SIGHUP.
+type reloader struct {
+	path  string
+	s     *server.MCPServer
+	authn *auth.Authenticator
+	state *tools.State // outlives every runtime
+
+	mu    sync.Mutex
+	tools map[string]bool // names installed by the last install
+	stamp fileStamp
+}
+
+func newReloader(path string, s *server.MCPServer, authn *auth.Authenticator) *reloader {
+	return &reloader{path: path, s: s, authn: authn, state: tools.NewState(), stamp: statFile(path)}
+}
+
+// install registers rt's tools on the live server and drops any tool the
+// previous runtime had that rt does not, such as the qbit_* tools after
+// qbittorrent is removed from the config. Both calls notify clients with
+// tools/list_changed.
+func (r *reloader) install(rt *runtime) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := rt.serverTools(r.state)
+	next := make(map[string]bool, len(list))
+	for _, t := range list {
+		next[t.Tool.Name] = true
+	}
+	var stale []string
+	for name := range r.tools {
+		if !next[name] {
+			stale = append(stale, name)
+		}
+	}
+
+	r.s.AddTools(list...)
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		r.s.DeleteTools(stale...)
+	}
+	r.authn.Update(rt.cfg.Clients)
+
+	r.tools = next
+}
+
+// reload loads the config file again and installs it. A config that fails to
+// load is rejected and the running one stays in place.
+func (r *reloader) reload(ctx context.Context) error {
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		internal.Errorf("config reload rejected, keeping the running config: %v", err)
+		return err
+	}
+	r.install(buildRuntime(ctx, cfg))
+	internal.Logf("reloaded config with %d services", len(cfg.Services))
+	return nil
+}
+
+// watch reloads on SIGHUP and whenever the config file's size or mtime
+// changes, until ctx is done. Polling rather than inotify keeps this working
+// for bind-mounted and ConfigMap files, which are replaced by rename.
+func (r *reloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			internal.Logf("SIGHUP received, reloading %s", r.path)
+			r.stamp = statFile(r.path)
+			r.reload(ctx)
+		case <-ticker.C:
+			stamp := statFile(r.path)
+			if stamp == r.stamp {
+				continue
+			}
+			r.stamp = stamp
+			internal.Logf("%s changed, reloading", r.path)
+			r.reload(ctx)
+		}
+	}
+}
+
+// fileStamp is the part of a file's metadata that changes when it is edited.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// A reload has to add and drop the download-client tools as their config
+// appears and disappears, and a broken edit must leave the running tools alone.
+func TestReloadSwapsToolsAndRejectsBrokenConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // keep the spec cache out of the real home
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(yml string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(yml), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A custom service has no default spec URL, so nothing is fetched.
+	const base = "services:\n  custom:\n    url: http://127.0.0.1:1\n"
+
+	write(base)
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.NewMCPServer("test", "0.0.0", server.WithToolCapabilities(true))
+	authn := auth.New(cfg.Clients)
+	r := newReloader(path, s, authn)
+	r.install(buildRuntime(context.Background(), cfg))
+
+	if s.GetTool("call_api") == nil {
+		t.Fatal("call_api was not registered")
+	}
+	if s.GetTool("qbit_list_torrents") != nil {
+		t.Fatal("qbit tools registered without qbittorrent configured")
+	}
+
+	write(base + "qbittorrent:\n  url: http://127.0.0.1:1\nclients:\n  laptop:\n    token: t1\n")
+	if err := r.reload(context.Background()); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if s.GetTool("qbit_list_torrents") == nil {
+		t.Error("qbit tools missing after qbittorrent was added")
+	}
+	if authn.Lookup("t1") == nil {
+		t.Error("client token from the reloaded config is not accepted")
+	}
+
+	write("services: [this is not a map\n")
+	if err := r.reload(context.Background()); err == nil {
+		t.Fatal("a broken config should be rejected")
+	}
+	if s.GetTool("qbit_list_torrents") == nil || s.GetTool("call_api") == nil {
+		t.Error("a rejected reload must keep the running tools")
+	}
+	if authn.Lookup("t1") == nil {
+		t.Error("a rejected reload must keep the running clients")
+	}
+
+	write(base)
+	if err := r.reload(context.Background()); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if s.GetTool("qbit_list_torrents") != nil {
+		t.Error("qbit tools still registered after qbittorrent was removed")
+	}
+	if s.GetTool("call_api") == nil {
+		t.Error("call_api dropped by a reload that should keep it")
+	}
+}
@@ -58,19 +58,9 @@ func unwrapURLError(err error, stage string) error {
 // Do calls a single SABnzbd mode and returns the raw JSON body. Empty params
 // are dropped so callers can pass optional values through unconditionally.
 func (c *Client) Do(ctx context.Context, mode string, params map[string]string) ([]byte, error) {
-	q := url.Values{}
-	q.Set("mode", mode)
-	q.Set("output", "json")
-	q.Set("apikey", c.apiKey)
-	for k, v := range params {
-		if v != "" {
-			q.Set(k, v)
-		}
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", c.url+"?"+q.Encode(), nil)
+	req, err := c.Request(ctx, mode, params)
 	if err != nil {
-		return nil, unwrapURLError(err, "creating request")
+		return nil, err
 	}
 
 	resp, err := c.http.Do(req)
@@ -101,6 +91,26 @@ func (c *Client) Do(ctx context.Context, mode string, params map[string]string)
 	return body, nil
 }
 
+// Request builds the request Do sends for mode without sending it, for dry
+// runs.
+func (c *Client) Request(ctx context.Context, mode string, params map[string]string) (*http.Request, error) {
+	q := url.Values{}
+	q.Set("mode", mode)
+	q.Set("output", "json")
+	q.Set("apikey", c.apiKey)
+	for k, v := range params {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, unwrapURLError(err, "creating request")
+	}
+	return req, nil
+}
+
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
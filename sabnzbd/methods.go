@@ -95,6 +95,23 @@ func (c *Client) Move(ctx context.Context, nzoID, target string) ([]byte, error)
 	})
 }
 
+// Version returns the SABnzbd version. mode=version needs no API key, so a
+// successful call proves the URL rather than the key.
+func (c *Client) Version(ctx context.Context) (string, error) {
+	data, err := c.Do(ctx, "version", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("decoding version: %w", err)
+	}
+	return result.Version, nil
+}
+
 func positive(n int) string {
 	if n <= 0 {
 		return ""
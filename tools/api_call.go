@@ -6,67 +6,109 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/format"
+	"github.com/jakenesler/navigatorr/openapi"
+	"github.com/jakenesler/navigatorr/policy"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-func registerAPICallTool(s *server.MCPServer, registry *arrservice.Registry, maxResponseSizeKB int, allowDestructive bool) {
+func registerAPICallTool(s *server.MCPServer, registry *arrservice.Registry, store *openapi.Store, maxResponseTokens int, g *guard) {
 	s.AddTool(
 		mcp.NewTool("call_api",
-			mcp.WithDescription("Make an authenticated API call to any configured *arr service. Returns the JSON response. Use fields/limit/filter to reduce response size."),
+			mcp.WithDescription("Make an authenticated API call to any configured *arr service. Returns the JSON response. Use fields/limit/filter to reduce response size, or expr to reshape it."),
 			mcp.WithString("service", mcp.Required(), mcp.Description("Service name (e.g. sonarr, radarr)")),
 			mcp.WithString("method", mcp.Description("HTTP method (default: GET)")),
-			mcp.WithString("path", mcp.Required(), mcp.Description("API path (e.g. /series, /movie). The API version prefix is added automatically.")),
-			mcp.WithString("query", mcp.Description("Query parameters as JSON object (e.g. {\"term\": \"breaking bad\"})")),
+			mcp.WithString("path", mcp.Required(), mcp.Description("API path (e.g. /series, /movie), or a spec path template (e.g. /series/{id}) filled from path_params. The API version prefix is added automatically.")),
+			mcp.WithString("path_params", mcp.Description("Values for the path template's placeholders as JSON object (e.g. {\"id\": 123}). Values are URL-escaped")),
+			mcp.WithString("query", mcp.Description("Query parameters as JSON object (e.g. {\"term\": \"breaking bad\"}). Arrays become repeated or delimited parameters as the spec declares (e.g. {\"episodeIds\": [1, 2]})")),
 			mcp.WithString("body", mcp.Description("Request body as JSON string")),
 			mcp.WithString("fields", mcp.Description("Comma-separated fields to include in response. Supports nested fields with dot notation (e.g. \"id,title,statistics.sizeOnDisk\"). For paginated responses, drill into arrays: \"records.id,records.title,records.status\" to select fields from each item in the records array.")),
-			mcp.WithString("filter", mcp.Description("Filter array results. Format: \"field:op:value\". Ops: contains, eq, ne, gt, lt (e.g. \"title:contains:Pirates\", \"year:gt:2000\", \"hasFile:eq:true\")")),
+			mcp.WithString("filter", mcp.Description("Filter array results with field:op:value predicates joined by and, or, not and parentheses. Ops: eq, ne, contains, startswith, endswith, in (comma list), regex, exists, gt, gte, lt, lte (numbers, sizes like 10GB, or dates), before, after (dates, or relative like -7d). Arrays match if any element does; field[all] requires every element (e.g. \"year:gte:2000 and not hasFile:eq:true\", \"seasons[].monitored:eq:false\", \"airDateUtc:after:-7d\")")),
+			mcp.WithString("sort", mcp.Description("Sort array results by comma-separated fields, each optionally :asc or :desc (e.g. \"statistics.sizeOnDisk:desc,title\"). After group_by, sort by group fields or aggregate columns (e.g. \"count:desc\")")),
+			mcp.WithString("group_by", mcp.Description("Comma-separated fields to group array results by. Returns one row per group with the aggregates (count by default)")),
+			mcp.WithString("aggregate", mcp.Description("Comma-separated aggregates: count, or sum, min, max, avg, distinct with :field (e.g. \"count,sum:statistics.sizeOnDisk\"). Without group_by, summarises the whole array in one row")),
 			mcp.WithString("limit", mcp.Description("Max number of items to return from array responses")),
+			mcp.WithBoolean("all_pages", mcp.Description("For paged GETs (/queue, /history, /wanted/missing, /blocklist, Seerr lists), fetch every page and merge the records before fields, filter and limit apply. Capped by max_records")),
+			mcp.WithString("max_records", mcp.Description(fmt.Sprintf("Most records all_pages collects (default %d, at most %d). A larger pageSize in query means fewer requests", defaultMaxRecords, maxRecordsLimit))),
+			mcp.WithString("expr", mcp.Description("JMESPath expression to reshape the response, applied before fields, filter, sort and limit (e.g. \"records[].{series: series.title, quality: quality.quality.name}\" flattens a queue page, \"[?hasFile==`false`].title\" lists missing titles, \"length(@)\" counts items). String literals take single quotes: \"[?status=='ended'].title\"")),
+			withMaxResponseTokens(),
+			withOutput(format.CompactJSON),
+			mcp.WithBoolean("skip_validation", mcp.Description("Send the request even if it does not match the service's OpenAPI spec. Only for endpoints the spec gets wrong")),
+			mcp.WithBoolean("reveal_secrets", mcp.Description("Return API keys, passwords and tokens unmasked. Refused unless a policy reveal_secrets rule allows it for this call")),
+			withConfirm(),
+			withDryRun(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-			return handleCallAPI(ctx, req, registry, maxResponseSizeKB, allowDestructive)
+			return handleCallAPI(ctx, req, registry, store, maxResponseTokens, g)
 		},
 	)
 }
 
-func handleCallAPI(ctx context.Context, req mcp.CallToolRequest, registry *arrservice.Registry, maxResponseSizeKB int, allowDestructive bool) (*mcp.CallToolResult, error) {
+func handleCallAPI(ctx context.Context, req mcp.CallToolRequest, registry *arrservice.Registry, store *openapi.Store, maxResponseTokens int, g *guard) (*mcp.CallToolResult, error) {
 	svcName := mcp.ParseString(req, "service", "")
 	method := strings.ToUpper(strings.TrimSpace(mcp.ParseString(req, "method", "GET")))
 	path := mcp.ParseString(req, "path", "")
-	queryStr := mcp.ParseString(req, "query", "")
 	bodyStr := mcp.ParseString(req, "body", "")
-	fieldsStr := mcp.ParseString(req, "fields", "")
-	filterStr := mcp.ParseString(req, "filter", "")
-	limitStr := mcp.ParseString(req, "limit", "")
 
 	if svcName == "" || path == "" {
 		return mcp.NewToolResultError("service and path are required"), nil
 	}
 
-	if method == "DELETE" && !allowDestructive {
-		return mcp.NewToolResultError("DELETE requests are disabled. Set allow_destructive: true in config.yaml to enable."), nil
-	}
-
 	svc, err := registry.Get(svcName)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	// A bad filter or sort is reported before the call, not by silently
+	// returning everything afterwards.
+	shaping, err := parseShape(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	outFormat, err := format.Parse(mcp.ParseString(req, "output", ""), format.CompactJSON)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	skipValidation := mcp.ParseBoolean(req, "skip_validation", false)
+	allPages := mcp.ParseBoolean(req, "all_pages", false)
+	if allPages && method != "GET" {
+		return mcp.NewToolResultError("all_pages only applies to GET requests"), nil
+	}
 
-	// Parse query params
-	var query map[string]string
-	if queryStr != "" {
-		var raw map[string]any
-		if err := json.Unmarshal([]byte(queryStr), &raw); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid query JSON: %v", err)), nil
+	// A templated path is filled in here, so everything below sees the
+	// concrete path while the policy and audit log also get the template.
+	var template string
+	pathParams, err := jsonObjectArg(req, "path_params")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if len(pathParams) > 0 || strings.Contains(path, "{") {
+		template = path
+		idx := specIndex(store, svcName)
+		if idx != nil && !skipValidation && idx.Endpoints[svc.Config.APIVersion+template] == nil && idx.Endpoints[template] == nil {
+			unknown := &openapi.ValidationError{Method: method, Path: template, Nearest: idx.Nearest(svc.Config.APIVersion+template, 5)}
+			return mcp.NewToolResultError(unknown.Error()), nil
 		}
-		query = make(map[string]string)
-		for k, v := range raw {
-			query[k] = fmt.Sprintf("%v", v)
+		if path, err = openapi.ExpandPath(template, pathParams); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
 		}
 	}
 
+	// Arrays and objects in the query are spelled the way the spec declares
+	// each parameter, repeated (ids=1&ids=2) unless it says otherwise.
+	rawQuery, err := jsonObjectArg(req, "query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	query, err := openapi.EncodeQuery(matchEndpoint(specIndex(store, svcName), svc.Config.APIVersion, path, method), rawQuery)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
 	// Parse body — handle both string and object forms.
 	// Some MCP clients may deserialize a JSON body string into an object.
 	var body []byte
@@ -84,7 +126,70 @@ func handleCallAPI(ctx context.Context, req mcp.CallToolRequest, registry *arrse
 		body = b
 	}
 
+	// The policy sees the decoded body, since destructive intent is often in
+	// it rather than in the verb: a POST to /command or deleteFiles: true.
+	var decoded any
+	json.Unmarshal(body, &decoded)
+	pr := policy.Request{Service: svcName, Type: svc.Config.ServiceType(svcName), Method: method, Path: path, Template: template, Query: query, Body: decoded}
+	// Check the call against the spec before the policy, confirmation or the
+	// service see it: a wrong path or a mistyped field is cheaper to fix from
+	// a precise error than from the service's own 400, and a malformed call
+	// is not worth confirming.
+	httpReq, err := svc.NewRequest(ctx, method, path, query, body)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	var endpoint *dryRunEndpoint
+	if !skipValidation {
+		var invalid error
+		endpoint, invalid = resolveEndpoint(ctx, specIndex(store, svcName), svcName, svc.Config.APIVersion, path, httpReq)
+		if invalid != nil && !g.dryRunning(req) {
+			return mcp.NewToolResultError(invalid.Error() + "\nUse get_endpoint_details for the full schema. If the spec is wrong about this endpoint, retry with skip_validation: true."), nil
+		}
+		// A raw path that resolved still groups under its template.
+		if pr.Template == "" && endpoint.Path != "" {
+			pr.Template = strings.TrimPrefix(endpoint.Path, svc.Config.APIVersion)
+		}
+	}
+	// A dry run stops here, with the request built exactly as DoRequest would
+	// build it and the policy's verdict reported rather than enforced.
+	if g.dryRunning(req) {
+		return g.render(pr, method+" "+path, httpReq, endpoint), nil
+	}
+	reveal := mcp.ParseBoolean(req, "reveal_secrets", false)
+	if reveal && !g.policy.RevealsSecrets(pr) {
+		return mcp.NewToolResultError(fmt.Sprintf("reveal_secrets is not allowed for %s %s on %s. Secrets stay masked unless a policy reveal_secrets rule covers the call.", method, path, svcName)), nil
+	}
+	if refusal := g.check(ctx, req, pr, method+" "+path, previewAPICall(svc, path, decoded)); refusal != nil {
+		return refusal, nil
+	}
+
+	// Responses come back with secrets masked, so a read-modify-write sends
+	// the mask back. Put the stored values in its place rather than
+	// overwriting the real key with asterisks.
+	if audit.Masked(decoded) {
+		restored, err := restoreMasked(ctx, svc, path, decoded)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		decoded = restored
+		body, _ = json.Marshal(decoded)
+		pr.Body = decoded
+	}
+
+	// Keep what a PUT or PATCH is about to overwrite, so undo_change can put
+	// it back.
+	var snapshots []audit.Snapshot
+	if g.audit != nil && (method == "PUT" || method == "PATCH") {
+		snapshots = takeSnapshots(ctx, svc, snapshotTargets(path, decoded))
+	}
+
+	start := time.Now()
 	respBody, statusCode, err := svc.DoRequest(ctx, method, path, query, body)
+	var auditID string
+	if method != "GET" && method != "HEAD" {
+		auditID = g.record(ctx, req, pr, start, statusCode, err, snapshots)
+	}
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("request failed: %v", err)), nil
 	}
@@ -92,80 +197,106 @@ func handleCallAPI(ctx context.Context, req mcp.CallToolRequest, registry *arrse
 	// A non-2xx status must surface as an error. *arr services return a JSON
 	// body on failure, so without this an auth or validation failure parses
 	// cleanly and reads as a successful call.
+	// Validation errors can echo the submitted values, secrets included.
+	redact := func(v any) any { return audit.RedactNames(v, sensitiveFields(store, svcName)) }
+	if reveal {
+		redact = nil
+	}
 	if statusCode < 200 || statusCode > 299 {
 		return mcp.NewToolResultError(fmt.Sprintf(
 			"%s %s failed: HTTP %d\n%s",
-			method, path, statusCode, truncate(string(respBody), 2000))), nil
+			method, path, statusCode, truncate(string(redactRaw(respBody, redact)), 2000))), nil
 	}
 
+	// Every page is gathered before shaping, so a filter or limit sees the
+	// whole list rather than the first page of it.
+	var pageNote string
+	if allPages {
+		maxRecords := defaultMaxRecords
+		if n, err := strconv.Atoi(mcp.ParseString(req, "max_records", "")); err == nil && n > 0 {
+			maxRecords = n
+		}
+		if respBody, pageNote, err = fetchAllPages(ctx, svc, path, query, respBody, maxRecords); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("all_pages: %v", err)), nil
+		}
+	}
+
+	res := renderResponse(ctx, respBody, statusCode, shaping, outFormat, responseBudget(req, maxResponseTokens), redact, g.results)
+	if pageNote != "" && !res.IsError {
+		res.Content = append(res.Content, mcp.NewTextContent(pageNote))
+	}
+	if len(snapshots) > 0 {
+		res.Content = append(res.Content, mcp.NewTextContent(fmt.Sprintf(
+			"Previous state saved with audit entry %s. undo_change with this audit_id restores it.", auditID)))
+	}
+	return res, nil
+}
+
+// jsonObjectArg reads an argument holding a JSON object, sent either as a
+// string or, by clients that decode it first, as an object.
+func jsonObjectArg(req mcp.CallToolRequest, name string) (map[string]any, error) {
+	raw, ok := req.GetArguments()[name]
+	if !ok || raw == nil || raw == "" {
+		return nil, nil
+	}
+	if obj, ok := raw.(map[string]any); ok {
+		return obj, nil
+	}
+	str, _ := raw.(string)
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(str), &obj); err != nil {
+		return nil, fmt.Errorf("invalid %s JSON: %v", name, err)
+	}
+	return obj, nil
+}
+
+// renderResponse turns a successful response into the tool result: secrets
+// masked by redact unless it is nil, shaped as requested, written in
+// outFormat, and checked against the token budget. A response that is cut
+// into chunks is kept in results, and fetch_more continues from the cursor
+// that comes with the first chunk.
+func renderResponse(ctx context.Context, respBody []byte, statusCode int, shaping shape, outFormat format.Format, budget int, redact func(any) any, results *resultStore) *mcp.CallToolResult {
 	// Parse response JSON
 	var jsonResp any
 	if err := json.Unmarshal(respBody, &jsonResp); err != nil {
 		// Not JSON, return raw
-		return mcp.NewToolResultText(fmt.Sprintf("status: %d\n%s", statusCode, string(respBody))), nil
+		return mcp.NewToolResultText(fmt.Sprintf("status: %d\n%s", statusCode, string(respBody)))
 	}
-
-	// Apply filter, fields, limit to responses
-	needsProcessing := fieldsStr != "" || filterStr != "" || limitStr != ""
-	if needsProcessing {
-		jsonResp = processResponse(jsonResp, fieldsStr, filterStr, limitStr)
+	if redact != nil {
+		jsonResp = redact(jsonResp)
 	}
 
-	// Response size guard — catch oversized responses before they eat the
-	// LLM's context window. Applies to both raw and processed responses.
-	maxResponseBytes := maxResponseSizeKB * 1024
-	data, _ := json.MarshalIndent(jsonResp, "", "  ")
-
-	if len(data) > maxResponseBytes {
-		// Find the largest array in the response (top-level or nested)
-		arr, fieldPath := findLargestArray(jsonResp)
-		if len(arr) > 0 {
-			var availableFields []string
-			if obj, ok := arr[0].(map[string]any); ok {
-				for k := range obj {
-					availableFields = append(availableFields, k)
-				}
-			}
-
-			sizeKB := len(data) / 1024
-			hint := fmt.Sprintf("⚠️ Response too large (%dKB, %d items", sizeKB, len(arr))
-			if fieldPath != "" {
-				hint += fmt.Sprintf(" in \"%s\"", fieldPath)
-			}
-			hint += "). This would consume excessive tokens.\n\n"
-			hint += "Retry this call with the fields param to select only the fields you need.\n"
-			if len(availableFields) > 0 {
-				prefix := ""
-				if fieldPath != "" {
-					prefix = fieldPath + "."
-				}
-				hint += fmt.Sprintf("Available fields: %s\n", joinWithPrefix(availableFields, prefix))
-				hint += fmt.Sprintf("\nExample: fields: \"%sid,%stitle,%sstatus\"\n", prefix, prefix, prefix)
-			}
-			hint += "\nYou can also use filter and limit params."
-			hint += "\nDo NOT retry this call without fields, filter, or limit."
-
-			return mcp.NewToolResultText(hint), nil
-		}
-
-		// No array found — generic size warning
-		return mcp.NewToolResultText(fmt.Sprintf(
-			"⚠️ Response too large (%dKB). Use fields param to reduce response size.",
-			len(data)/1024)), nil
+	// The expression sees the masked response, so it cannot select a secret
+	// back out.
+	jsonResp, err := applyShape(jsonResp, shaping)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
 	}
 
-	return mcp.NewToolResultText(string(data)), nil
+	// Over the token budget, the response is degraded until it fits and, if
+	// it comes to chunks, kept for fetch_more. Shaping works in place, so it
+	// is decoded again for the store, letting fetch_more reshape it from
+	// scratch.
+	return fitBudget(jsonResp, 0, budget, shaping.fields != "", shaping.output(outFormat), func(next int) string {
+		var stored any
+		json.Unmarshal(respBody, &stored)
+		if redact != nil {
+			stored = redact(stored)
+		}
+		compact, _ := json.Marshal(stored)
+		return results.keep(compact, clientName(ctx), shaping, outFormat, next)
+	})
 }
 
-// processResponse applies filter, fields, and limit to the API response.
+// processResponse applies the shaping arguments to the API response.
 // Handles both top-level arrays and object responses with nested arrays
 // (e.g. {records: [...], page: 1, totalRecords: 50}).
 // Fields like "records.title" will drill into the "records" array and
 // pick "title" from each item.
-func processResponse(resp any, fieldsStr, filterStr, limitStr string) any {
+func processResponse(resp any, s shape) any {
 	// Top-level array — apply directly
 	if arr, ok := resp.([]any); ok {
-		return processArray(arr, fieldsStr, filterStr, limitStr)
+		return processArray(arr, s)
 	}
 
 	// Object response — check for nested array field selection
@@ -174,8 +305,8 @@ func processResponse(resp any, fieldsStr, filterStr, limitStr string) any {
 		return resp
 	}
 
-	if fieldsStr != "" {
-		fields := parseFields(fieldsStr)
+	if s.fields != "" {
+		fields := parseFields(s.fields)
 
 		// Group fields by their top-level key to detect array drilling
 		// e.g. "records.title,records.year,page" → {records: [title, year], page: []}
@@ -217,8 +348,9 @@ func processResponse(resp any, fieldsStr, filterStr, limitStr string) any {
 				if !ok {
 					continue
 				}
-				subFieldsStr := strings.Join(subFields, ",")
-				result[key] = processArray(arr, subFieldsStr, filterStr, limitStr)
+				sub := s
+				sub.fields = strings.Join(subFields, ",")
+				result[key] = processArray(arr, sub)
 			}
 			// Drill into sub-objects
 			for key, subPaths := range nested {
@@ -226,7 +358,9 @@ func processResponse(resp any, fieldsStr, filterStr, limitStr string) any {
 				if !ok {
 					continue
 				}
-				result[key] = processResponse(sub, strings.Join(subPaths, ","), filterStr, limitStr)
+				inner := s
+				inner.fields = strings.Join(subPaths, ",")
+				result[key] = processResponse(sub, inner)
 			}
 			return result
 		}
@@ -235,11 +369,11 @@ func processResponse(resp any, fieldsStr, filterStr, limitStr string) any {
 		return pickFields(obj, fields)
 	}
 
-	// No fields but filter/limit — find and process nested arrays
-	if filterStr != "" || limitStr != "" {
+	// No fields but other shaping — find and process nested arrays
+	if !s.empty() {
 		for k, v := range obj {
 			if arr, ok := v.([]any); ok {
-				obj[k] = processArray(arr, "", filterStr, limitStr)
+				obj[k] = processArray(arr, s)
 			}
 		}
 	}
@@ -247,20 +381,28 @@ func processResponse(resp any, fieldsStr, filterStr, limitStr string) any {
 	return obj
 }
 
-// processArray applies filter, limit, and field selection to an array.
-func processArray(arr []any, fieldsStr, filterStr, limitStr string) any {
-	if filterStr != "" {
-		arr = applyFilter(arr, filterStr)
+// processArray filters an array, then either summarises it into grouped rows
+// or sorts it, and finally applies the limit and field selection. Sorting
+// after grouping lets the rows be ordered by their aggregates, as in
+// "count:desc".
+func processArray(arr []any, s shape) any {
+	if s.filter != nil {
+		arr = applyFilter(arr, s.filter)
+	}
+	if s.grouped() {
+		arr = groupItems(arr, s.groupBy, s.aggs)
+	}
+	if len(s.sort) > 0 {
+		sortItems(arr, s.sort)
 	}
 
-	if limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit < len(arr) {
-			arr = arr[:limit]
-		}
+	if s.limit > 0 && s.limit < len(arr) {
+		arr = arr[:s.limit]
 	}
 
-	if fieldsStr != "" {
-		fields := parseFields(fieldsStr)
+	// Grouped rows are already just the columns asked for.
+	if s.fields != "" && !s.grouped() {
+		fields := parseFields(s.fields)
 		result := make([]any, len(arr))
 		for i, item := range arr {
 			if obj, ok := item.(map[string]any); ok {
@@ -354,38 +496,6 @@ func pickFields(obj map[string]any, fields []string) map[string]any {
 	return result
 }
 
-// applyFilter filters array items. Format: "field:op:value"
-// Ops: contains, eq, ne, gt, lt
-func applyFilter(arr []any, filterStr string) []any {
-	parts := strings.SplitN(filterStr, ":", 3)
-	if len(parts) != 3 {
-		return arr
-	}
-	field, op, value := parts[0], parts[1], parts[2]
-
-	// Non-nil so a filter that matches nothing marshals as [] rather than null.
-	result := make([]any, 0, len(arr))
-	for _, item := range arr {
-		obj, ok := item.(map[string]any)
-		if !ok {
-			continue
-		}
-		fieldVal := getNestedField(obj, field)
-		if fieldVal == nil {
-			// An absent field is "not equal" to any value; every other op
-			// needs something to compare against.
-			if op == "ne" {
-				result = append(result, item)
-			}
-			continue
-		}
-		if matchFilter(fieldVal, op, value) {
-			result = append(result, item)
-		}
-	}
-	return result
-}
-
 // truncate caps a string for inclusion in a tool result, so a large error
 // body cannot eat the LLM's context window.
 func truncate(s string, max int) string {
@@ -394,42 +504,3 @@ func truncate(s string, max int) string {
 	}
 	return s[:max] + fmt.Sprintf("\n... (truncated, %d bytes total)", len(s))
 }
-
-// getNestedField retrieves a value using dot notation.
-func getNestedField(obj map[string]any, field string) any {
-	parts := strings.SplitN(field, ".", 2)
-	val, ok := obj[parts[0]]
-	if !ok {
-		return nil
-	}
-	if len(parts) == 1 {
-		return val
-	}
-	if nested, ok := val.(map[string]any); ok {
-		return getNestedField(nested, parts[1])
-	}
-	return nil
-}
-
-// matchFilter checks if a value matches the filter operation.
-func matchFilter(fieldVal any, op, value string) bool {
-	fieldStr := fmt.Sprintf("%v", fieldVal)
-
-	switch op {
-	case "contains":
-		return strings.Contains(strings.ToLower(fieldStr), strings.ToLower(value))
-	case "eq":
-		return strings.EqualFold(fieldStr, value)
-	case "ne":
-		return !strings.EqualFold(fieldStr, value)
-	case "gt":
-		fv, err1 := strconv.ParseFloat(fieldStr, 64)
-		cv, err2 := strconv.ParseFloat(value, 64)
-		return err1 == nil && err2 == nil && fv > cv
-	case "lt":
-		fv, err1 := strconv.ParseFloat(fieldStr, 64)
-		cv, err2 := strconv.ParseFloat(value, 64)
-		return err1 == nil && err2 == nil && fv < cv
-	}
-	return false
-}
@@ -2,7 +2,6 @@ package tools
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/jakenesler/navigatorr/arrservice"
 	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/openapi"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
@@ -32,7 +32,7 @@ func callAPI(t *testing.T, handler http.HandlerFunc, args map[string]any) *mcp.C
 	}
 	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: args}}
 
-	res, err := handleCallAPI(context.Background(), req, registry, 50, false)
+	res, err := handleCallAPI(context.Background(), req, registry, nil, 12800, testGuard(false))
 	if err != nil {
 		t.Fatalf("handleCallAPI returned a transport error: %v", err)
 	}
@@ -122,56 +122,6 @@ func TestCallAPIAuthAndDeleteGuard(t *testing.T) {
 	}
 }
 
-func TestApplyFilter(t *testing.T) {
-	items := []any{
-		map[string]any{"title": "Alpha", "year": float64(2001), "hasFile": true},
-		map[string]any{"title": "Beta", "year": float64(2020), "hasFile": false},
-		map[string]any{"title": "Gamma", "year": float64(2015)}, // hasFile absent
-	}
-
-	tests := []struct {
-		name   string
-		filter string
-		want   []string
-	}{
-		{"contains is case-insensitive", "title:contains:alp", []string{"Alpha"}},
-		{"gt compares numerically", "year:gt:2010", []string{"Beta", "Gamma"}},
-		{"lt compares numerically", "year:lt:2010", []string{"Alpha"}},
-		{"eq matches", "hasFile:eq:true", []string{"Alpha"}},
-		{"ne includes items missing the field", "hasFile:ne:true", []string{"Beta", "Gamma"}},
-		{"no matches yields empty, not null", "title:contains:zzz", []string{}},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := applyFilter(items, tt.filter)
-			if got == nil {
-				t.Fatal("applyFilter returned nil; it must marshal as [] not null")
-			}
-			var titles []string
-			for _, it := range got {
-				titles = append(titles, it.(map[string]any)["title"].(string))
-			}
-			if len(titles) != len(tt.want) {
-				t.Fatalf("got %v, want %v", titles, tt.want)
-			}
-			for i := range titles {
-				if titles[i] != tt.want[i] {
-					t.Fatalf("got %v, want %v", titles, tt.want)
-				}
-			}
-
-			data, err := json.Marshal(got)
-			if err != nil {
-				t.Fatal(err)
-			}
-			if string(data) == "null" {
-				t.Error("empty filter result marshaled to null")
-			}
-		})
-	}
-}
-
 func TestTruncate(t *testing.T) {
 	if got := truncate("short", 100); got != "short" {
 		t.Errorf("truncate should leave short strings alone, got %q", got)
@@ -228,3 +178,101 @@ func TestCallAPISingleLevelArrayFieldsStillWork(t *testing.T) {
 		t.Errorf("records.title returned unrequested fields:\n%s", got)
 	}
 }
+
+// A call that does not fit the spec is refused with the fields at fault
+// before it reaches the service, unless skip_validation says the spec is
+// wrong.
+func TestCallAPIValidatesAgainstSpec(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	const spec = `{"openapi":"3.0.0","info":{"title":"t","version":"1"},"paths":{
+  "/api/v3/series/{id}":{"put":{"summary":"Update series",
+    "parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"integer"}}],
+    "requestBody":{"content":{"application/json":{"schema":{"type":"object","properties":{
+      "seriesType":{"type":"string","enum":["standard","daily","anime"]}}}}}},
+    "responses":{"200":{"description":"ok"}}}},
+  "/api/v3/series":{"get":{"summary":"List series","responses":{"200":{"description":"ok"}}}}}}`
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/openapi.json" {
+			w.Write([]byte(spec))
+			return
+		}
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3", OpenAPIURL: srv.URL + "/openapi.json"},
+	}}
+	store := openapi.NewStore(cfg)
+	store.LoadAll(context.Background())
+	call := func(args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: args}}
+		res, err := handleCallAPI(context.Background(), req, arrservice.NewRegistry(cfg), store, 12800, testGuard(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	bad := map[string]any{"service": "sonarr", "method": "PUT", "path": "/series/3", "body": `{"seriesType":"weekly"}`}
+	if res := call(bad); !res.IsError || !strings.Contains(resultText(t, res), `body field seriesType`) {
+		t.Errorf("an out-of-enum value should be refused: %s", resultText(t, res))
+	}
+	if res := call(map[string]any{"service": "sonarr", "path": "/serie"}); !res.IsError || !strings.Contains(resultText(t, res), "GET /api/v3/series") {
+		t.Errorf("an unknown path should suggest the nearest endpoint: %s", resultText(t, res))
+	}
+	if len(sent) != 0 {
+		t.Fatalf("invalid calls reached the service: %v", sent)
+	}
+
+	bad["skip_validation"] = true
+	if res := call(bad); res.IsError || len(sent) != 1 {
+		t.Errorf("skip_validation should send the call (sent %v): %s", sent, resultText(t, res))
+	}
+}
+
+func TestCallAPIPathTemplate(t *testing.T) {
+	var got string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.EscapedPath()
+		w.Write([]byte(`{}`))
+	}
+
+	res := callAPI(t, handler, map[string]any{"path": "/series/{id}/{name}", "path_params": map[string]any{"id": float64(123), "name": "a/b"}})
+	if res.IsError || got != "/api/v3/series/123/a%2Fb" {
+		t.Errorf("requested %q: %s", got, resultText(t, res))
+	}
+
+	got = ""
+	res = callAPI(t, handler, map[string]any{"path": "/series/{id}", "path_params": `{"seriesId": 1}`})
+	if !res.IsError || !strings.Contains(resultText(t, res), "missing id") || got != "" {
+		t.Errorf("a missing placeholder should be refused before sending (requested %q): %s", got, resultText(t, res))
+	}
+}
+
+// Array query values used to reach the service as the literal "[1 2 3]".
+func TestCallAPIMultiValueQuery(t *testing.T) {
+	var got []string
+	res := callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()["episodeIds"]
+		w.Write([]byte(`[]`))
+	}, map[string]any{"path": "/episode", "query": `{"episodeIds":[1,2,3]}`})
+	if res.IsError || strings.Join(got, ",") != "1,2,3" {
+		t.Errorf("episodeIds = %q: %s", got, resultText(t, res))
+	}
+}
+
+// A filter that does not parse used to return the whole response unfiltered.
+func TestCallAPIRejectsBadFilter(t *testing.T) {
+	called := false
+	res := callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.Write([]byte(`[]`))
+	}, map[string]any{"path": "/series", "filter": "title:like:x"})
+	if !res.IsError || !strings.Contains(resultText(t, res), "invalid filter") || called {
+		t.Errorf("bad filter should be refused before the call (called=%v): %s", called, resultText(t, res))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/auth"
 	"github.com/jakenesler/navigatorr/openapi"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -92,6 +93,7 @@ const statusTimeout = 5 * time.Second
 func handleListServices(ctx context.Context, registry *arrservice.Registry, store *openapi.Store) (*mcp.CallToolResult, error) {
 	type svcInfo struct {
 		Name       string `json:"name"`
+		Type       string `json:"type"`
 		URL        string `json:"url"`
 		AuthMethod string `json:"auth_method"`
 		Status     string `json:"status"`
@@ -99,7 +101,14 @@ func handleListServices(ctx context.Context, registry *arrservice.Registry, stor
 		Endpoints  int    `json:"endpoints,omitempty"`
 	}
 
-	names := registry.List()
+	// A scoped network client only sees the services it may call.
+	client := auth.FromContext(ctx)
+	names := []string{}
+	for _, name := range registry.List() {
+		if client.AllowsService(name) {
+			names = append(names, name)
+		}
+	}
 	services := make([]svcInfo, len(names))
 
 	pingCtx, cancel := context.WithTimeout(ctx, statusTimeout)
@@ -113,6 +122,7 @@ func handleListServices(ctx context.Context, registry *arrservice.Registry, stor
 		}
 		info := svcInfo{
 			Name:       name,
+			Type:       svc.Config.ServiceType(name),
 			URL:        svc.Config.URL,
 			AuthMethod: svc.Config.AuthMethod,
 		}
@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func registerAuditTools(s *server.MCPServer, log *audit.Log) {
+	// audit_log
+	s.AddTool(
+		mcp.NewTool("audit_log",
+			mcp.WithDescription("Recent mutating calls made through navigatorr (every non-GET call_api request, torrent and SABnzbd actions), newest first. Each entry has the client, tool, service, method, path, body with secrets masked, HTTP status and duration"),
+			mcp.WithString("service", mcp.Description("Only entries for this service (e.g. sonarr, qbittorrent)")),
+			mcp.WithString("tool", mcp.Description("Only entries from this tool (e.g. call_api)")),
+			mcp.WithString("since", mcp.Description("Start of the time range: RFC 3339 (2026-01-02T15:04:05Z), a date (2026-01-02), or an age like 24h or 7d")),
+			mcp.WithString("until", mcp.Description("End of the time range, in the same forms as since")),
+			mcp.WithNumber("limit", mcp.Description("Maximum entries to return (default 20)")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			f := audit.Filter{
+				Service: mcp.ParseString(req, "service", ""),
+				Tool:    mcp.ParseString(req, "tool", ""),
+				Limit:   int(mcp.ParseFloat64(req, "limit", 20)),
+			}
+			var err error
+			if f.Since, err = parseTimeArg(mcp.ParseString(req, "since", ""), time.Now()); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("since: %v", err)), nil
+			}
+			if f.Until, err = parseTimeArg(mcp.ParseString(req, "until", ""), time.Now()); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("until: %v", err)), nil
+			}
+
+			// Over-fetch when scoping by client, so the limit applies to what
+			// the client can see.
+			client := auth.FromContext(ctx)
+			limit := f.Limit
+			if client != nil {
+				f.Limit = 0
+			}
+			entries, err := log.Query(f)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			visible := []audit.Entry{}
+			for _, e := range entries {
+				if client.AllowsService(e.Service) {
+					visible = append(visible, e)
+				}
+				if limit > 0 && len(visible) == limit {
+					break
+				}
+			}
+
+			data, _ := json.MarshalIndent(visible, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
+		},
+	)
+}
+
+// parseTimeArg reads a point in time given as RFC 3339, a date, or an age
+// before now such as "24h", "7d" or "-7d". An empty string is the zero time.
+func parseTimeArg(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	age := strings.TrimPrefix(s, "-")
+	if days, ok := strings.CutSuffix(age, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(age); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time, date, or age like 24h or 7d", s)
+}
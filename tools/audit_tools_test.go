@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Mutating call_api requests land in the audit log with their status and a
+// masked body; reads do not.
+func TestCallAPIWritesAuditLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	log := audit.New(config.AuditConfig{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	g := newGuard(policy.New(config.PolicyConfig{}), false, false, log, NewState())
+	for _, args := range []map[string]any{
+		{"service": "sonarr", "path": "/series"},
+		{"service": "sonarr", "method": "POST", "path": "/downloadclient", "body": `{"name":"qbit","password":"hunter2"}`},
+	} {
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: args}}
+		if _, err := handleCallAPI(context.Background(), req, registry, nil, 12800, g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := server.NewMCPServer("test", "0.0.0")
+	registerAuditTools(s, log)
+	var entries []audit.Entry
+	text := resultText(t, callTool(t, s, "audit_log", map[string]any{"service": "sonarr", "since": "1h"}))
+	if err := json.Unmarshal([]byte(text), &entries); err != nil {
+		t.Fatalf("audit_log output: %v\n%s", err, text)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("want only the POST recorded, got %d entries:\n%s", len(entries), text)
+	}
+	e := entries[0]
+	if e.Method != "POST" || e.Path != "/downloadclient" || e.Status != http.StatusCreated || e.Tool != "call_api" {
+		t.Errorf("entry fields: %+v", e)
+	}
+	if body, _ := e.Body.(map[string]any); body["password"] != audit.Mask || body["name"] != "qbit" {
+		t.Errorf("body should be recorded with secrets masked: %v", e.Body)
+	}
+}
+
+func TestParseTimeArg(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"2026-03-01T08:00:00Z", time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{"2026-03-01", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"7d", now.AddDate(0, 0, -7)},
+		{"-7d", now.AddDate(0, 0, -7)},
+		{"90m", now.Add(-90 * time.Minute)},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeArg(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseTimeArg(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := parseTimeArg("yesterday", now); err == nil {
+		t.Error("expected an error for an unparseable time")
+	}
+}
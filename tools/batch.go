@@ -0,0 +1,252 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/openapi"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	maxBatchSize  = 100
+	minItemTokens = 100 // an item's share of the budget never drops below this
+)
+
+// rateLimiter spaces requests to one service at least interval apart. A batch
+// of 40 episode updates would otherwise land on Sonarr's SQLite database all
+// at once.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the next request may go out, or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	at := time.Now()
+	if l.next.After(at) {
+		at = l.next
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimits returns a limiter for each service with a rate_limit.
+func rateLimits(services map[string]config.ServiceConfig) map[string]*rateLimiter {
+	limits := make(map[string]*rateLimiter)
+	for name, svc := range services {
+		if svc.RateLimit > 0 {
+			limits[name] = &rateLimiter{interval: time.Duration(float64(time.Second) / svc.RateLimit)}
+		}
+	}
+	return limits
+}
+
+func registerBatchTool(s *server.MCPServer, registry *arrservice.Registry, store *openapi.Store, maxResponseTokens, concurrency int, limits map[string]*rateLimiter, g *guard) {
+	concurrency = max(concurrency, 1) // a config that was not loaded leaves it 0
+	s.AddTool(
+		mcp.NewTool("call_api_batch",
+			mcp.WithDescription(fmt.Sprintf("Run up to %d call_api requests in one call, several at a time, and return a status and result for each plus a summary. Use it for the same change to many items (e.g. monitoring 40 episodes) or many lookups. Each request is checked against the policy on its own, so one refused request does not stop the rest.", maxBatchSize)),
+			mcp.WithString("requests", mcp.Required(), mcp.Description("JSON array of call_api arguments, one object per request (e.g. [{\"service\": \"sonarr\", \"method\": \"PUT\", \"path\": \"/episode/{id}\", \"path_params\": {\"id\": 12}, \"body\": {...}}]). Every call_api argument works, including fields and limit, which keep each result small")),
+			mcp.WithString("concurrency", mcp.Description(fmt.Sprintf("How many requests run at once (default and maximum %d). Services with a rate_limit are also paced", concurrency))),
+			withMaxResponseTokens(),
+			withDryRun(),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return handleCallAPIBatch(ctx, req, registry, store, maxResponseTokens, concurrency, limits, g), nil
+		},
+	)
+}
+
+// batchSummary counts the outcomes of a batch.
+type batchSummary struct {
+	Total       int `json:"total"`
+	OK          int `json:"ok"`
+	Failed      int `json:"failed"`
+	NeedConfirm int `json:"needs_confirm,omitempty"`
+	DryRun      int `json:"dry_run,omitempty"`
+}
+
+// batchResult is one request's outcome. Status is ok, error, confirm (the
+// result holds the preview and its token) or dry_run.
+type batchResult struct {
+	Index  int             `json:"index"`
+	Call   string          `json:"call"`
+	Status string          `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Note   string          `json:"note,omitempty"`
+}
+
+func handleCallAPIBatch(ctx context.Context, req mcp.CallToolRequest, registry *arrservice.Registry, store *openapi.Store, maxResponseTokens, concurrency int, limits map[string]*rateLimiter, g *guard) *mcp.CallToolResult {
+	items, err := batchRequests(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	if n, err := strconv.Atoi(mcp.ParseString(req, "concurrency", "")); err == nil && n > 0 && n < concurrency {
+		concurrency = n
+	}
+	// The budget is shared out, so the whole batch fits where one call_api
+	// result would.
+	itemBudget := max(responseBudget(req, maxResponseTokens)/len(items), minItemTokens)
+	dryRun := g.dryRunning(req)
+
+	results := make([]batchResult, len(items))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			results[i] = runBatchItem(ctx, i, item, dryRun, registry, store, itemBudget, limits, g)
+		}()
+	}
+	wg.Wait()
+
+	summary := batchSummary{Total: len(results)}
+	for _, r := range results {
+		switch r.Status {
+		case "ok":
+			summary.OK++
+		case "confirm":
+			summary.NeedConfirm++
+		case "dry_run":
+			summary.DryRun++
+		default:
+			summary.Failed++
+		}
+	}
+	data, _ := json.Marshal(struct {
+		Summary batchSummary  `json:"summary"`
+		Results []batchResult `json:"results"`
+	}{summary, results})
+	res := mcp.NewToolResultText(string(data))
+	if summary.NeedConfirm > 0 {
+		var pending []string
+		for _, r := range results {
+			if r.Status == "confirm" {
+				pending = append(pending, strconv.Itoa(r.Index))
+			}
+		}
+		res.Content = append(res.Content, mcp.NewTextContent(fmt.Sprintf("Requests with status confirm (index %s) did not run. Show their previews to the user, and if they agree, send a batch of only those requests, each with confirm set to its token. The others have already run; sending them again would run them twice.", strings.Join(pending, ", "))))
+	}
+	return res
+}
+
+// batchRequests reads the requests argument, sent either as a JSON string or,
+// by clients that decode it first, as an array.
+func batchRequests(req mcp.CallToolRequest) ([]map[string]any, error) {
+	raw := req.GetArguments()["requests"]
+	if s, ok := raw.(string); ok {
+		if err := json.Unmarshal([]byte(s), &raw); err != nil {
+			return nil, fmt.Errorf("requests must be a JSON array of call_api arguments: %v", err)
+		}
+	}
+	list, ok := raw.([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("requests must be a non-empty JSON array of call_api arguments")
+	}
+	if len(list) > maxBatchSize {
+		return nil, fmt.Errorf("%d requests is more than the %d a batch takes; split it", len(list), maxBatchSize)
+	}
+	items := make([]map[string]any, len(list))
+	for i, v := range list {
+		item, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("request %d is not an object of call_api arguments", i)
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// runBatchItem runs one request through call_api, so it gets the same
+// validation, policy, confirmation and audit entry as a call of its own.
+func runBatchItem(ctx context.Context, index int, args map[string]any, dryRun bool, registry *arrservice.Registry, store *openapi.Store, budget int, limits map[string]*rateLimiter, g *guard) batchResult {
+	svcName, _ := args["service"].(string)
+	method, _ := args["method"].(string)
+	path, _ := args["path"].(string)
+	r := batchResult{Index: index, Call: strings.TrimSpace(fmt.Sprintf("%s %s %s", svcName, strings.ToUpper(cmp.Or(method, "GET")), path))}
+
+	// The client's scoping only sees call_api_batch and its own arguments,
+	// so each request is checked here as the call_api call it is.
+	client := auth.FromContext(ctx)
+	switch {
+	case !client.AllowsTool("call_api"):
+		r.Status, r.Error = "error", fmt.Sprintf("tool \"call_api\" is not allowed for client %q, and each batch request is a call_api call", client.Name)
+		return r
+	case !client.AllowsService(svcName):
+		r.Status, r.Error = "error", fmt.Sprintf("service %q is not allowed for client %q", svcName, client.Name)
+		return r
+	}
+	if dryRun {
+		args["dry_run"] = true
+	}
+	itemReq := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api_batch", Arguments: args}}
+	if l := limits[svcName]; l != nil && !g.dryRunning(itemReq) {
+		if err := l.wait(ctx); err != nil {
+			r.Status, r.Error = "error", fmt.Sprintf("not run: %v", err)
+			return r
+		}
+	}
+
+	res, _ := handleCallAPI(ctx, itemReq, registry, store, budget, g)
+	text, notes := splitResult(res)
+	r.Note = strings.Join(notes, "\n")
+	if res.IsError {
+		r.Status, r.Error = "error", text
+		return r
+	}
+
+	r.Status = "ok"
+	if json.Valid([]byte(text)) {
+		r.Result = json.RawMessage(text)
+	} else {
+		// A table or CSV result is carried as a string.
+		r.Result, _ = json.Marshal(text)
+	}
+	switch {
+	case g.dryRunning(itemReq):
+		r.Status = "dry_run"
+	case isPreview(text):
+		r.Status = "confirm"
+	}
+	return r
+}
+
+// splitResult returns a tool result's text and the notes that follow it.
+func splitResult(res *mcp.CallToolResult) (text string, notes []string) {
+	for i, c := range res.Content {
+		tc, ok := c.(mcp.TextContent)
+		switch {
+		case !ok:
+		case i == 0:
+			text = tc.Text
+		default:
+			notes = append(notes, tc.Text)
+		}
+	}
+	return text, notes
+}
@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Each request in a batch succeeds or fails on its own, within the
+// concurrency limit, and the policy refuses the destructive one alone.
+func TestCallAPIBatch(t *testing.T) {
+	var inFlight, peak, deletes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		if r.Method == "DELETE" {
+			deletes.Add(1)
+		}
+		if r.URL.Path == "/api/v3/series/99" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "NotFound"}`))
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/api/v3/series/")
+		fmt.Fprintf(w, `{"id": %s, "title": "Series %s", "overview": "long"}`, id, id)
+	}))
+	defer srv.Close()
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+
+	var requests []string
+	for _, id := range []int{1, 2, 3, 4, 99} {
+		requests = append(requests, fmt.Sprintf(`{"service": "sonarr", "path": "/series/{id}", "path_params": {"id": %d}, "fields": "id,title"}`, id))
+	}
+	requests = append(requests, `{"service": "sonarr", "method": "DELETE", "path": "/series/1"}`, `{"service": "lidarr", "path": "/artist"}`)
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api_batch", Arguments: map[string]any{
+		"requests": "[" + strings.Join(requests, ",") + "]", "concurrency": "2",
+	}}}
+	res := handleCallAPIBatch(context.Background(), req, registry, nil, 12800, 4, nil, testGuard(false))
+
+	var out struct {
+		Summary batchSummary
+		Results []batchResult
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &out); err != nil {
+		t.Fatalf("%v: %s", err, resultText(t, res))
+	}
+	if want := (batchSummary{Total: 7, OK: 4, Failed: 3}); out.Summary != want {
+		t.Errorf("summary = %+v, want %+v", out.Summary, want)
+	}
+	for i, want := range []string{`{"id":1,"title":"Series 1"}`, `{"id":2,"title":"Series 2"}`} {
+		if got := string(out.Results[i].Result); out.Results[i].Index != i || got != want {
+			t.Errorf("result %d = %s, want %s", i, got, want)
+		}
+	}
+	for i, want := range map[int]string{4: "HTTP 404", 5: "disabled", 6: "lidarr"} {
+		if r := out.Results[i]; r.Status != "error" || !strings.Contains(r.Error, want) {
+			t.Errorf("result %d = %+v, want an error mentioning %q", i, r, want)
+		}
+	}
+	if out.Results[5].Call != "sonarr DELETE /series/1" {
+		t.Errorf("call = %q", out.Results[5].Call)
+	}
+	if deletes.Load() != 0 {
+		t.Error("the refused DELETE reached the service")
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("%d requests ran at once, over the concurrency of 2", p)
+	}
+}
+
+// A batch with a request that needs confirming runs the rest at once. The
+// note asks for only the confirm requests to be sent again, and sending them
+// that way runs each request exactly once.
+func TestCallAPIBatchConfirm(t *testing.T) {
+	var commands, deletes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			commands.Add(1)
+		case "DELETE":
+			deletes.Add(1)
+		}
+		w.Write([]byte(`{"id": 1, "title": "Series 1"}`))
+	}))
+	defer srv.Close()
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	g := newGuard(policy.New(config.PolicyConfig{}), true, true, nil, NewState())
+	batch := func(requests string) (batchSummary, []batchResult, []string) {
+		t.Helper()
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api_batch", Arguments: map[string]any{"requests": requests}}}
+		res := handleCallAPIBatch(context.Background(), req, registry, nil, 12800, 4, nil, g)
+		var out struct {
+			Summary batchSummary
+			Results []batchResult
+		}
+		text, notes := splitResult(res)
+		if err := json.Unmarshal([]byte(text), &out); err != nil {
+			t.Fatalf("%v: %s", err, text)
+		}
+		return out.Summary, out.Results, notes
+	}
+
+	summary, results, notes := batch(`[{"service": "sonarr", "method": "POST", "path": "/command", "body": {"name": "RefreshSeries"}},
+	  {"service": "sonarr", "method": "DELETE", "path": "/series/1"}]`)
+	if want := (batchSummary{Total: 2, OK: 1, NeedConfirm: 1}); summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "(index 1)") || !strings.Contains(notes[0], "only those requests") {
+		t.Errorf("notes = %q", notes)
+	}
+	var preview confirmPreview
+	if err := json.Unmarshal(results[1].Result, &preview); err != nil || preview.Confirm == "" {
+		t.Fatalf("no token in %s", results[1].Result)
+	}
+
+	summary, _, _ = batch(`[{"service": "sonarr", "method": "DELETE", "path": "/series/1", "confirm": "` + preview.Confirm + `"}]`)
+	if summary.OK != 1 || commands.Load() != 1 || deletes.Load() != 1 {
+		t.Errorf("summary %+v after %d commands and %d deletes, want 1 of each", summary, commands.Load(), deletes.Load())
+	}
+}
+
+// A client scoped to call_api_batch without call_api cannot reach call_api
+// through the batch.
+func TestCallAPIBatchChecksCallAPIScope(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("%s %s reached the service", r.Method, r.URL.Path)
+	}))
+	defer srv.Close()
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	client := auth.New(map[string]config.ClientConfig{"scoped": {Token: "t", Tools: []string{"call_api_batch"}}}).Lookup("t")
+	ctx := auth.NewContext(context.Background(), client)
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api_batch", Arguments: map[string]any{
+		"requests": `[{"service": "sonarr", "path": "/series"}]`,
+	}}}
+	text := resultText(t, handleCallAPIBatch(ctx, req, registry, nil, 12800, 4, nil, testGuard(false)))
+	if !strings.Contains(text, `\"call_api\" is not allowed for client \"scoped\"`) {
+		t.Errorf("batch = %s", text)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := rateLimits(map[string]config.ServiceConfig{"sonarr": {RateLimit: 50}, "radarr": {}})
+	if l["radarr"] != nil {
+		t.Error("a service without rate_limit got a limiter")
+	}
+	start := time.Now()
+	for range 6 {
+		if err := l["sonarr"].wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("6 requests at 50/s took %v, want at least 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := &rateLimiter{interval: time.Hour, next: time.Now().Add(time.Hour)}
+	if err := slow.wait(ctx); err == nil {
+		t.Error("wait ignored a cancelled context")
+	}
+}
+
+func TestBatchRequestsErrors(t *testing.T) {
+	for _, arg := range []any{"", "[]", "{}", `[1]`, "[" + strings.Repeat("{},", maxBatchSize) + "{}]"} {
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{"requests": arg}}}
+		if _, err := batchRequests(req); err == nil {
+			t.Errorf("requests %.20q was accepted", arg)
+		}
+	}
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Arguments: map[string]any{"requests": []any{map[string]any{"path": "/series"}}}}}
+	if items, err := batchRequests(req); err != nil || len(items) != 1 {
+		t.Errorf("decoded array: %v, %v", items, err)
+	}
+}
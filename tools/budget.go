@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// longTextChars is where a string counts as long text for the degradation
+// ladder. Overviews and descriptions run to several hundred characters;
+// titles, paths and dates stay well under it.
+const longTextChars = 200
+
+// estimateTokens approximates how many tokens a model's tokenizer makes of
+// JSON text, without shipping a tokenizer. Words and numbers cost about a
+// token per four characters, punctuation about a token per two (`":"` and
+// `},{` tend to merge), and each run of whitespace a token, which is what
+// makes indented JSON dearer than it looks.
+func estimateTokens(data []byte) int {
+	n := 0
+	for i := 0; i < len(data); {
+		j := i + 1
+		switch c := data[i]; {
+		case isWordByte(c):
+			for j < len(data) && isWordByte(data[j]) {
+				j++
+			}
+			n += (j - i + 3) / 4
+		case c == ' ' || c == '\n' || c == '\t' || c == '\r':
+			for j < len(data) && (data[j] == ' ' || data[j] == '\n' || data[j] == '\t' || data[j] == '\r') {
+				j++
+			}
+			n++
+		default:
+			for j < len(data) && !isWordByte(data[j]) && data[j] != ' ' && data[j] != '\n' && data[j] != '\t' && data[j] != '\r' {
+				j++
+			}
+			n += (j - i + 1) / 2
+		}
+		i = j
+	}
+	return n
+}
+
+// isWordByte reports whether c belongs to a word or number. Bytes of
+// multi-byte UTF-8 characters count as word bytes.
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c >= 0x80
+}
+
+// responseBudget is the token budget for a call: the configured maximum, or
+// less when the call's max_response_tokens asks for less.
+func responseBudget(req mcp.CallToolRequest, maxResponseTokens int) int {
+	if n, err := strconv.Atoi(mcp.ParseString(req, "max_response_tokens", "")); err == nil && n > 0 && n < maxResponseTokens {
+		return n
+	}
+	return maxResponseTokens
+}
+
+// withMaxResponseTokens adds the max_response_tokens argument to a tool whose
+// result goes through fitBudget.
+func withMaxResponseTokens() mcp.ToolOption {
+	return mcp.WithString("max_response_tokens", mcp.Description("Token budget for this response, below the configured maximum. Over budget, empty fields are dropped first, then long text such as overviews, then items are returned a chunk at a time"))
+}
+
+// fitBudget renders a decoded response in out's format within budget tokens.
+// A response over budget is degraded a step at a time until it fits: empty
+// fields are dropped, then long text unless keepText is set because the
+// caller picked its fields, and finally the largest array is cut into chunks,
+// from offset, with a cursor from more for the rest. A continuation (offset
+// above 0) takes every step, as the chunk before it did. Whatever was dropped
+// is named in a note after the JSON.
+func fitBudget(resp any, offset, budget int, keepText bool, out output, more func(next int) string) *mcp.CallToolResult {
+	text := out.render(resp)
+	fullTokens := estimateTokens([]byte(text))
+	if offset == 0 && fullTokens <= budget {
+		return mcp.NewToolResultText(text)
+	}
+
+	var dropped []string
+	resp, empty := dropEmpty(resp)
+	if empty {
+		dropped = append(dropped, "empty fields")
+	}
+	if !keepText {
+		var long []string
+		resp, long = dropLongText(resp)
+		if len(long) > 0 {
+			dropped = append(dropped, "long text ("+strings.Join(long, ", ")+"; name it in fields to keep it)")
+		}
+	}
+	var degraded string
+	if len(dropped) > 0 {
+		degraded = fmt.Sprintf("To fit the %d-token budget, dropped %s.", budget, strings.Join(dropped, " and "))
+	}
+
+	if offset == 0 {
+		text = out.render(resp)
+		if estimateTokens([]byte(text)) <= budget {
+			res := mcp.NewToolResultText(text)
+			if degraded != "" {
+				res.Content = append(res.Content, mcp.NewTextContent(degraded))
+			}
+			return res
+		}
+	}
+
+	chunk, note, ok := chunkResult(resp, offset, budget, fullTokens, out, more)
+	if !ok {
+		return oversizedHint(resp, fullTokens, budget)
+	}
+	res := mcp.NewToolResultText(chunk)
+	if degraded != "" {
+		note += "\n" + degraded
+	}
+	res.Content = append(res.Content, mcp.NewTextContent(note))
+	return res
+}
+
+// dropEmpty returns v without null fields, empty strings, empty arrays and
+// empty objects, and whether there were any. Array items are kept even when
+// empty, so positions and counts still hold.
+func dropEmpty(v any) (any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		dropped := false
+		for k, val := range v {
+			val, d := dropEmpty(val)
+			dropped = dropped || d
+			if isEmpty(val) {
+				dropped = true
+				continue
+			}
+			out[k] = val
+		}
+		return out, dropped
+	case []any:
+		out := make([]any, len(v))
+		dropped := false
+		for i, item := range v {
+			var d bool
+			out[i], d = dropEmpty(item)
+			dropped = dropped || d
+		}
+		return out, dropped
+	}
+	return v, false
+}
+
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// dropLongText returns v without fields holding strings of longTextChars or
+// more, and the sorted names of the fields it dropped.
+func dropLongText(v any) (any, []string) {
+	names := map[string]bool{}
+	var walk func(v any) any
+	walk = func(v any) any {
+		switch v := v.(type) {
+		case map[string]any:
+			out := make(map[string]any, len(v))
+			for k, val := range v {
+				if s, ok := val.(string); ok && len(s) >= longTextChars {
+					names[k] = true
+					continue
+				}
+				out[k] = walk(val)
+			}
+			return out
+		case []any:
+			out := make([]any, len(v))
+			for i, item := range v {
+				out[i] = walk(item)
+			}
+			return out
+		}
+		return v
+	}
+	v = walk(v)
+	dropped := make([]string, 0, len(names))
+	for name := range names {
+		dropped = append(dropped, name)
+	}
+	sort.Strings(dropped)
+	return v, dropped
+}
+
+// oversizedHint is returned when even a single item is over budget, or there
+// is no array to cut: what to ask for instead.
+func oversizedHint(resp any, tokens, budget int) *mcp.CallToolResult {
+	// Find the largest array in the response (top-level or nested)
+	arr, fieldPath := findLargestArray(resp)
+	if len(arr) > 0 {
+		var availableFields []string
+		if obj, ok := arr[0].(map[string]any); ok {
+			for k := range obj {
+				availableFields = append(availableFields, k)
+			}
+		}
+
+		hint := fmt.Sprintf("⚠️ Response too large (about %d tokens, %d items", tokens, len(arr))
+		if fieldPath != "" {
+			hint += fmt.Sprintf(" in \"%s\"", fieldPath)
+		}
+		hint += fmt.Sprintf("), and a single item is over the %d-token budget. This would consume excessive tokens.\n\n", budget)
+		hint += "Retry this call with the fields param to select only the fields you need.\n"
+		if len(availableFields) > 0 {
+			prefix := ""
+			if fieldPath != "" {
+				prefix = fieldPath + "."
+			}
+			hint += fmt.Sprintf("Available fields: %s\n", joinWithPrefix(availableFields, prefix))
+			hint += fmt.Sprintf("\nExample: fields: \"%sid,%stitle,%sstatus\"\n", prefix, prefix, prefix)
+		}
+		hint += "\nYou can also use filter, limit and expr params."
+		hint += "\nDo NOT retry this call without fields, filter, or limit."
+
+		return mcp.NewToolResultText(hint)
+	}
+
+	// No array found — generic size warning
+	return mcp.NewToolResultText(fmt.Sprintf(
+		"⚠️ Response too large (about %d tokens, over the %d-token budget). Use fields or expr to reduce response size.",
+		tokens, budget))
+}
@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	v := map[string]any{"title": "The Expanse", "year": 2015, "genres": []any{"Drama", "Sci-Fi"}, "statistics": map[string]any{"sizeOnDisk": 123456789}}
+	compact, _ := json.Marshal(v)
+	indented, _ := json.MarshalIndent(v, "", "  ")
+	c, i := estimateTokens(compact), estimateTokens(indented)
+	// Real tokenizers put this at around 30 tokens compact.
+	if c < 20 || c > 45 {
+		t.Errorf("compact estimate = %d", c)
+	}
+	if i <= c {
+		t.Errorf("indented (%d) should cost more than compact (%d)", i, c)
+	}
+}
+
+// Over budget, the response loses empty fields first, then long text, and
+// only then is it cut into chunks.
+func TestCallAPIDegradesToBudget(t *testing.T) {
+	series := func(overview string, n int) string {
+		var items []string
+		for id := 1; id <= n; id++ {
+			items = append(items, fmt.Sprintf(`{"id": %d, "title": "Series %d", "overview": %q, "network": null, "tags": [], "images": {}}`, id, id, overview))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	}
+	long := strings.Repeat("A long synopsis. ", 20)
+
+	for _, tt := range []struct {
+		name      string
+		resp      string
+		args      map[string]any
+		wantKeys  string
+		wantItems int
+		note      string
+	}{
+		{"fits as is", series("Short.", 3), map[string]any{}, "id,images,network,overview,tags,title", 3, ""},
+		{"empty fields go first", series("Short.", 20), map[string]any{"max_response_tokens": "550"}, "id,overview,title", 20, "dropped empty fields."},
+		{"then long text", series(long, 20), map[string]any{"max_response_tokens": "550"}, "id,title", 20, "long text (overview;"},
+		{"then chunks", series(long, 20), map[string]any{"max_response_tokens": "100"}, "id,title", 6, "fetch_more with cursor"},
+		{"fields keep long text", series(long, 20), map[string]any{"max_response_tokens": "400", "fields": "id,overview"}, "id,overview", 2, "Showing items 1-2"},
+		{"budget only lowers", series("Short.", 3), map[string]any{"max_response_tokens": "999999"}, "id,images,network,overview,tags,title", 3, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args["path"] = "/series"
+			res := callAPI(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(tt.resp)) }, tt.args)
+			text := res.Content[0].(mcp.TextContent).Text
+			var items []map[string]any
+			if err := json.Unmarshal([]byte(text), &items); err != nil {
+				t.Fatalf("%v: %s", err, resultText(t, res))
+			}
+			if strings.Contains(text, "\n") {
+				t.Error("response is indented")
+			}
+			if len(items) != tt.wantItems {
+				t.Errorf("%d items, want %d", len(items), tt.wantItems)
+			}
+			var keys []string
+			for k := range items[0] {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if got := strings.Join(keys, ","); got != tt.wantKeys {
+				t.Errorf("keys = %s, want %s", got, tt.wantKeys)
+			}
+			var note string
+			if len(res.Content) > 1 {
+				note = res.Content[1].(mcp.TextContent).Text
+			}
+			if tt.note == "" && note != "" || !strings.Contains(note, tt.note) {
+				t.Errorf("note = %q, want %q", note, tt.note)
+			}
+		})
+	}
+}
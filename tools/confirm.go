@@ -0,0 +1,276 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// confirmTTL is how long a confirmation token stays valid. Long enough for
+// the model to read the preview and repeat the call, short enough that a
+// token found later in the transcript is useless.
+const confirmTTL = 5 * time.Minute
+
+// withConfirm adds the confirm argument to a tool that can run destructive
+// actions.
+func withConfirm() mcp.ToolOption {
+	return mcp.WithString("confirm", mcp.Description("Confirmation token from the preview of this exact call. Only needed when a destructive action returned one."))
+}
+
+type confirmerKey struct{}
+
+// WithConfirmer returns a copy of ctx in which destructive calls are put to
+// confirm, with the preview as its message, instead of returning a preview and
+// a token. The CLI uses it, since its tokens would not outlive the command.
+func WithConfirmer(ctx context.Context, confirm func(message string) bool) context.Context {
+	return context.WithValue(ctx, confirmerKey{}, confirm)
+}
+
+func confirmerFrom(ctx context.Context) func(string) bool {
+	confirm, _ := ctx.Value(confirmerKey{}).(func(string) bool)
+	return confirm
+}
+
+// confirmations holds the tokens handed out with previews. Each token is bound
+// to one call — tool, arguments and client — and can be redeemed once.
+type confirmations struct {
+	mu     sync.Mutex
+	tokens map[string]pendingCall
+}
+
+type pendingCall struct {
+	fingerprint string
+	expires     time.Time
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{tokens: make(map[string]pendingCall)}
+}
+
+// issue returns a new token for the call.
+func (c *confirmations) issue(fingerprint string) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for t, p := range c.tokens {
+		if now.After(p.expires) {
+			delete(c.tokens, t)
+		}
+	}
+	c.tokens[token] = pendingCall{fingerprint: fingerprint, expires: now.Add(confirmTTL)}
+	return token
+}
+
+// redeem consumes token if it was issued for this call and has not expired.
+func (c *confirmations) redeem(token, fingerprint string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.tokens[token]
+	if !ok || p.fingerprint != fingerprint || time.Now().After(p.expires) {
+		return false
+	}
+	delete(c.tokens, token)
+	return true
+}
+
+// callFingerprint identifies a call by tool, arguments (minus confirm) and
+// client, so a token cannot be replayed against a different target or by a
+// different client. json.Marshal sorts map keys, so equal calls match.
+func callFingerprint(ctx context.Context, req mcp.CallToolRequest) string {
+	args := make(map[string]any)
+	for k, v := range req.GetArguments() {
+		if k != "confirm" {
+			args[k] = v
+		}
+	}
+	client := ""
+	if c := auth.FromContext(ctx); c != nil {
+		client = c.Name
+	}
+	data, _ := json.Marshal(args)
+	return client + "\x00" + req.Params.Name + "\x00" + string(data)
+}
+
+// elicitConfirmation asks the human directly when the MCP client supports
+// elicitation. answered is false when it does not, or the request failed, and
+// the caller falls back to a confirmation token.
+func elicitConfirmation(ctx context.Context, message string) (accepted, answered bool) {
+	srv := server.ServerFromContext(ctx)
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	if srv == nil || !ok || session.GetClientCapabilities().Elicitation == nil {
+		return false, false
+	}
+
+	res, err := srv.RequestElicitation(ctx, mcp.ElicitationRequest{
+		Params: mcp.ElicitationParams{
+			Message: message,
+			RequestedSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"confirm": map[string]any{"type": "boolean", "title": "Run it", "default": false},
+				},
+				"required": []string{"confirm"},
+			},
+		},
+	})
+	if err != nil {
+		return false, false
+	}
+	if res.Action != mcp.ElicitationResponseActionAccept {
+		return false, true
+	}
+	content, _ := res.Content.(map[string]any)
+	confirmed, _ := content["confirm"].(bool)
+	return confirmed, true
+}
+
+// confirmPreview is what a destructive call returns instead of running.
+type confirmPreview struct {
+	ConfirmationRequired bool   `json:"confirmation_required"`
+	Action               string `json:"action"`
+	Reason               string `json:"reason"`
+	Affects              any    `json:"affects,omitempty"`
+	Confirm              string `json:"confirm"`
+	ExpiresIn            string `json:"expires_in"`
+	Instructions         string `json:"instructions"`
+}
+
+// isPreview reports whether a tool result's text is a confirmation preview
+// rather than the call's own result.
+func isPreview(text string) bool {
+	var p confirmPreview
+	return json.Unmarshal([]byte(text), &p) == nil && p.ConfirmationRequired && p.Confirm != ""
+}
+
+func previewMessage(what, reason string, affects any) string {
+	msg := fmt.Sprintf("%s (%s).", what, reason)
+	if affects != nil {
+		data, _ := json.MarshalIndent(affects, "", "  ")
+		msg += "\n\nThis affects:\n" + string(data)
+	}
+	return msg + "\n\nRun it?"
+}
+
+// maxPreviewLookups caps the GETs a preview makes, so confirming a bulk edit
+// of a whole library does not fetch every item.
+const maxPreviewLookups = 25
+
+// splitResourcePath strips the query and trailing slash from an API path and
+// splits it into segments. item reports whether it addresses one resource by
+// numeric id, as /series/12 does.
+func splitResourcePath(path string) (clean string, segments []string, item bool) {
+	clean = strings.TrimSuffix(strings.SplitN(path, "?", 2)[0], "/")
+	segments = strings.Split(strings.TrimPrefix(clean, "/"), "/")
+	_, err := strconv.Atoi(segments[len(segments)-1])
+	return clean, segments, err == nil && len(segments) > 1
+}
+
+// previewAPICall resolves what a destructive call_api request would touch:
+// the resource in the path itself, and the ids named in the body, e.g.
+// seriesIds in a series editor call or seriesId in a DeleteSeries command.
+// Each is fetched and summarised by its title or name.
+func previewAPICall(svc *arrservice.Service, path string, body any) func(context.Context) any {
+	return func(ctx context.Context) any {
+		clean, segments, item := splitResourcePath(path)
+
+		var targets []string
+		if item {
+			targets = append(targets, clean)
+		}
+		if obj, ok := body.(map[string]any); ok {
+			keys := make([]string, 0, len(obj))
+			for k := range obj {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				resource, ids := idsFromField(k, obj[k])
+				if resource == "" {
+					resource = segments[0] // "ids" in /queue/bulk means queue items
+				}
+				for _, id := range ids {
+					targets = append(targets, "/"+resource+"/"+id)
+				}
+			}
+		}
+		if len(targets) == 0 {
+			return nil
+		}
+
+		var affects []map[string]any
+		for i, t := range targets {
+			if i == maxPreviewLookups {
+				affects = append(affects, map[string]any{"more": len(targets) - i})
+				break
+			}
+			affects = append(affects, lookupSummary(ctx, svc, t))
+		}
+		return affects
+	}
+}
+
+// idsFromField reads ids out of a body field named like seriesIds, movieId or
+// ids. resource is the path segment the ids belong to, empty for plain "ids".
+func idsFromField(key string, v any) (resource string, ids []string) {
+	switch {
+	case key == "ids":
+	case strings.HasSuffix(key, "Ids"):
+		resource = strings.TrimSuffix(key, "Ids")
+	case strings.HasSuffix(key, "Id"):
+		resource = strings.TrimSuffix(key, "Id")
+	default:
+		return "", nil
+	}
+	values, ok := v.([]any)
+	if !ok {
+		values = []any{v}
+	}
+	for _, x := range values {
+		if n, ok := x.(float64); ok {
+			ids = append(ids, strconv.FormatInt(int64(n), 10))
+		}
+	}
+	return resource, ids
+}
+
+// summaryFields are the fields that identify an item to a human.
+var summaryFields = []string{"id", "title", "name", "sourceTitle", "artistName", "authorName", "year", "path"}
+
+func lookupSummary(ctx context.Context, svc *arrservice.Service, path string) map[string]any {
+	summary := map[string]any{"resource": path}
+	resp, status, err := svc.DoRequest(ctx, "GET", path, nil, nil)
+	switch {
+	case err != nil:
+		summary["error"] = err.Error()
+		return summary
+	case status < 200 || status > 299:
+		summary["error"] = fmt.Sprintf("HTTP %d", status)
+		return summary
+	}
+	var obj map[string]any
+	if json.Unmarshal(resp, &obj) != nil {
+		return summary
+	}
+	for _, f := range summaryFields {
+		if v, ok := obj[f]; ok {
+			summary[f] = v
+		}
+	}
+	return summary
+}
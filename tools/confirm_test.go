@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/jakenesler/navigatorr/qbit"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// With confirm_destructive on, a destructive call first returns a preview
+// naming what it would delete, and only runs when repeated with the token.
+func TestDestructiveCallNeedsConfirmation(t *testing.T) {
+	var deletes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`{"id":` + strings.TrimPrefix(r.URL.Path, "/api/v3/series/") + `,"title":"Firefly","path":"/tv/Firefly"}`))
+		default:
+			deletes = append(deletes, r.Method+" "+r.URL.Path)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	g := newGuard(policy.New(config.PolicyConfig{}), true, true, nil, NewState())
+	call := func(args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: args}}
+		res, err := handleCallAPI(context.Background(), req, registry, nil, 12800, g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	args := map[string]any{"service": "sonarr", "method": "PUT", "path": "/series/editor", "body": `{"seriesIds":[7],"deleteFiles":true}`}
+	var preview confirmPreview
+	if err := json.Unmarshal([]byte(resultText(t, call(args))), &preview); err != nil || preview.Confirm == "" {
+		t.Fatalf("expected a preview with a token, got err=%v", err)
+	}
+	if len(deletes) != 0 {
+		t.Fatalf("the preview ran the call: %v", deletes)
+	}
+	affects, _ := json.Marshal(preview.Affects)
+	if !strings.Contains(string(affects), "Firefly") {
+		t.Errorf("preview should resolve the series title: %s", affects)
+	}
+
+	other := map[string]any{"service": "sonarr", "method": "PUT", "path": "/series/editor", "body": `{"seriesIds":[8],"deleteFiles":true}`, "confirm": preview.Confirm}
+	if res := call(other); !res.IsError {
+		t.Errorf("a token must not confirm a different call: %s", resultText(t, res))
+	}
+
+	args["confirm"] = preview.Confirm
+	if res := call(args); res.IsError || len(deletes) != 1 {
+		t.Fatalf("confirmed call did not run (requests %v): %s", deletes, resultText(t, res))
+	}
+	if res := call(args); !res.IsError || len(deletes) != 1 {
+		t.Errorf("a token must only work once (requests %v)", deletes)
+	}
+}
+
+func TestTorrentPreviewNamesTorrents(t *testing.T) {
+	var deleted bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/torrents/info":
+			w.Write([]byte(`[{"hash":"abc","name":"Ubuntu ISO"},{"hash":"def","name":"Other"}]`))
+		case "/api/v2/torrents/delete":
+			deleted = true
+		default:
+			w.Write([]byte("Ok."))
+		}
+	}))
+	defer srv.Close()
+
+	s := server.NewMCPServer("test", "0.0.0")
+	registerQbitTools(s, qbit.NewClient(srv.URL, "u", "p"), newGuard(policy.New(config.PolicyConfig{}), true, true, nil, NewState()))
+
+	text := resultText(t, callTool(t, s, "qbit_manage_torrent", map[string]any{"action": "delete", "hashes": "ABC"}))
+	if !strings.Contains(text, "Ubuntu ISO") || strings.Contains(text, "Other") {
+		t.Errorf("preview should name only the targeted torrent:\n%s", text)
+	}
+	if deleted {
+		t.Error("the preview deleted the torrent")
+	}
+}
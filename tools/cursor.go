@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/format"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// cursorTTL is how long an oversized response stays available to fetch_more
+// after it was last read. Long enough to page through a list while working on
+// it; the response is a snapshot, so much older than this it is stale anyway.
+const cursorTTL = 10 * time.Minute
+
+// maxStoredResults bounds how many oversized responses are held at once. Each
+// can be several megabytes; the oldest goes first.
+const maxStoredResults = 16
+
+// storedResult is an oversized response kept for fetch_more: the decoded
+// response as the client was allowed to see it, secrets masked unless they
+// were revealed, re-encoded compactly.
+type storedResult struct {
+	data    []byte
+	client  string
+	stored  time.Time
+	expires time.Time
+}
+
+// cursor is a position in a stored result: the shaping applied to it, the
+// index of the next item of its largest array, and the output format.
+type cursor struct {
+	result *storedResult
+	shape  shape
+	offset int
+	out    format.Format
+}
+
+// resultStore holds the oversized responses and the cursors into them. Every
+// chunk hands out a fresh cursor for the items after it, and a cursor can be
+// used more than once, so a retried fetch_more returns the same chunk.
+type resultStore struct {
+	mu      sync.Mutex
+	cursors map[string]cursor
+}
+
+func newResultStore() *resultStore {
+	return &resultStore{cursors: make(map[string]cursor)}
+}
+
+// keep stores a response for client and returns a cursor at offset.
+func (s *resultStore) keep(data []byte, client string, sh shape, out format.Format, offset int) string {
+	now := time.Now()
+	r := &storedResult{data: data, client: client, stored: now, expires: now.Add(cursorTTL)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	live := map[*storedResult]bool{}
+	var oldest *storedResult
+	for token, c := range s.cursors {
+		if now.After(c.result.expires) {
+			delete(s.cursors, token)
+			continue
+		}
+		live[c.result] = true
+		if oldest == nil || c.result.stored.Before(oldest.stored) {
+			oldest = c.result
+		}
+	}
+	if len(live) >= maxStoredResults {
+		for token, c := range s.cursors {
+			if c.result == oldest {
+				delete(s.cursors, token)
+			}
+		}
+	}
+	return s.issueLocked(cursor{result: r, shape: sh, offset: offset, out: out})
+}
+
+// advance returns a cursor into the same result as c.
+func (s *resultStore) advance(c cursor, sh shape, out format.Format, offset int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issueLocked(cursor{result: c.result, shape: sh, offset: offset, out: out})
+}
+
+func (s *resultStore) issueLocked(c cursor) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	s.cursors[token] = c
+	return token
+}
+
+// lookup finds a cursor issued to client that has not expired, and keeps its
+// result alive for another cursorTTL.
+func (s *resultStore) lookup(token, client string) (cursor, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cursors[token]
+	if !ok || c.result.client != client || time.Now().After(c.result.expires) {
+		return cursor{}, false
+	}
+	c.result.expires = time.Now().Add(cursorTTL)
+	return c, true
+}
+
+// clientName identifies the caller a cursor belongs to, so one network client
+// cannot read what another fetched.
+func clientName(ctx context.Context) string {
+	if c := auth.FromContext(ctx); c != nil {
+		return c.Name
+	}
+	return ""
+}
+
+// chunkResult renders as many items of the largest array in resp as fit in
+// budget tokens, starting at offset, with the rest of the response around
+// them, written in out's format, and a note on which items these are. more is
+// called for a cursor when items remain after the chunk. ok is false when
+// there is no array to cut, or its next item alone is over budget.
+func chunkResult(resp any, offset, budget, fullTokens int, out output, more func(next int) string) (text, note string, ok bool) {
+	arr, path := findLargestArray(resp)
+	offset = min(offset, len(arr))
+	rest := arr[offset:]
+
+	n := len(rest)
+	for n > 0 {
+		text = out.render(withArray(resp, path, rest[:n]))
+		tokens := estimateTokens([]byte(text))
+		if tokens <= budget {
+			break
+		}
+		// Shrink in proportion to the overshoot; the fields around the array
+		// make this an estimate, so it may take a few rounds.
+		n = min(n-1, n*budget/tokens)
+	}
+	if n == 0 && len(rest) > 0 {
+		return "", "", false
+	}
+	if n == 0 {
+		text = out.render(withArray(resp, path, rest))
+	}
+
+	where := ""
+	if path != "" {
+		where = fmt.Sprintf(" in \"%s\"", path)
+	}
+	end := offset + n
+	if end == len(arr) {
+		if n == 0 {
+			return text, fmt.Sprintf("No items after item %d%s.", offset, where), true
+		}
+		return text, fmt.Sprintf("Items %d-%d of %d%s, the last of them.", offset+1, end, len(arr), where), true
+	}
+
+	note = fmt.Sprintf("⚠️ Response too large (about %d tokens, %d items%s). Showing items %d-%d.\n", fullTokens, len(arr), where, offset+1, end)
+	note += fmt.Sprintf("fetch_more with cursor: \"%s\" returns the next items without calling the service again. ", more(end))
+	note += "It also takes fields, filter, sort, limit and expr to reshape the stored response, starting again from its first item."
+	if obj, ok := arr[0].(map[string]any); ok {
+		var available []string
+		for k := range obj {
+			available = append(available, k)
+		}
+		prefix := ""
+		if path != "" {
+			prefix = path + "."
+		}
+		note += fmt.Sprintf("\nAvailable fields: %s", joinWithPrefix(available, prefix))
+	}
+	return text, note, true
+}
+
+// withArray returns resp with the array at the dotted path replaced by arr.
+// The objects along the path are copied, so resp itself is left alone.
+func withArray(resp any, path string, arr []any) any {
+	if path == "" {
+		return arr
+	}
+	obj, ok := resp.(map[string]any)
+	if !ok {
+		return resp
+	}
+	key, rest, _ := strings.Cut(path, ".")
+	out := make(map[string]any, len(obj))
+	for k, v := range obj {
+		out[k] = v
+	}
+	if rest == "" {
+		out[key] = arr
+	} else {
+		out[key] = withArray(obj[key], rest, arr)
+	}
+	return out
+}
+
+func registerFetchMoreTool(s *server.MCPServer, maxResponseTokens int, g *guard) {
+	s.AddTool(
+		mcp.NewTool("fetch_more",
+			mcp.WithDescription("Continue an oversized call_api response from its cursor, without calling the service again. Returns the next items that fit, and a new cursor while more remain. Pass fields, filter, sort, limit or expr to reshape the stored response, starting again from its first item. Cursors expire 10 minutes after last use."),
+			mcp.WithString("cursor", mcp.Required(), mcp.Description("Cursor from a call_api or fetch_more response")),
+			mcp.WithString("fields", mcp.Description("Comma-separated fields to include, as in call_api")),
+			mcp.WithString("filter", mcp.Description("field:op:value predicates joined by and, or, not, as in call_api")),
+			mcp.WithString("sort", mcp.Description("Comma-separated sort fields, each optionally :asc or :desc")),
+			mcp.WithString("group_by", mcp.Description("Comma-separated fields to group items by")),
+			mcp.WithString("aggregate", mcp.Description("Aggregates such as count,sum:statistics.sizeOnDisk")),
+			mcp.WithString("limit", mcp.Description("Max number of items")),
+			mcp.WithString("expr", mcp.Description("JMESPath expression applied before the other arguments, as in call_api")),
+			withMaxResponseTokens(),
+			mcp.WithString("output", mcp.Description(fmt.Sprintf("Output format: %s (default: as the call that returned the cursor)", format.Names))),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return handleFetchMore(ctx, req, maxResponseTokens, g.results), nil
+		},
+	)
+}
+
+func handleFetchMore(ctx context.Context, req mcp.CallToolRequest, maxResponseTokens int, results *resultStore) *mcp.CallToolResult {
+	token := mcp.ParseString(req, "cursor", "")
+	c, ok := results.lookup(token, clientName(ctx))
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("cursor %q is unknown or has expired. Repeat the call_api call, with fields or filter to keep it small.", token))
+	}
+
+	// New shaping replaces the cursor's, and since it can change which items
+	// there are and their order, the position goes back to the start.
+	sh, offset := c.shape, c.offset
+	for _, name := range shapeArgs {
+		if mcp.ParseString(req, name, "") != "" {
+			var err error
+			if sh, err = parseShape(req); err != nil {
+				return mcp.NewToolResultError(err.Error())
+			}
+			offset = 0
+			break
+		}
+	}
+
+	f, err := format.Parse(mcp.ParseString(req, "output", ""), c.out)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+
+	var resp any
+	json.Unmarshal(c.result.data, &resp)
+	resp, err = applyShape(resp, sh)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+
+	return fitBudget(resp, offset, responseBudget(req, maxResponseTokens), sh.fields != "", sh.output(f), func(next int) string {
+		return results.advance(c, sh, f, next)
+	})
+}
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var cursorPattern = regexp.MustCompile(`cursor: "([0-9a-f]+)"`)
+
+// An oversized response comes back in chunks: the first from call_api, the
+// rest from fetch_more, without the service being asked again.
+func TestOversizedResponseContinuesWithFetchMore(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var items []string
+		for id := 1; id <= 600; id++ {
+			items = append(items, fmt.Sprintf(`{"id": %d, "title": "Series %d", "overview": "%s", "apiKey": "hunter2"}`, id, id, strings.Repeat("x", 150)))
+		}
+		w.Write([]byte(`{"page": 1, "records": [` + strings.Join(items, ",") + `]}`))
+	}))
+	defer srv.Close()
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	g := testGuard(false)
+	ctx := auth.NewContext(context.Background(), &auth.Client{Name: "desktop"})
+
+	// chunk returns the ids in a result and the cursor for the rest.
+	chunk := func(res *mcp.CallToolResult) (ids []int, cursor string) {
+		t.Helper()
+		if res.IsError {
+			t.Fatal(resultText(t, res))
+		}
+		text := res.Content[0].(mcp.TextContent).Text
+		if n := estimateTokens([]byte(text)); n > 12800 {
+			t.Errorf("chunk is %d tokens, over the budget", n)
+		}
+		if strings.Contains(text, "hunter2") {
+			t.Error("a chunk leaked a secret")
+		}
+		var env struct{ Records []struct{ ID int } }
+		if err := json.Unmarshal([]byte(text), &env); err != nil {
+			t.Fatalf("%v: %s", err, text)
+		}
+		for _, r := range env.Records {
+			ids = append(ids, r.ID)
+		}
+		if len(res.Content) > 1 {
+			if m := cursorPattern.FindStringSubmatch(res.Content[1].(mcp.TextContent).Text); m != nil {
+				cursor = m[1]
+			}
+		}
+		return ids, cursor
+	}
+	fetchMore := func(ctx context.Context, args map[string]any) *mcp.CallToolResult {
+		return handleFetchMore(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "fetch_more", Arguments: args}}, 12800, g.results)
+	}
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: map[string]any{"service": "sonarr", "path": "/series"}}}
+	res, err := handleCallAPI(ctx, req, registry, nil, 12800, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, cursor := chunk(res)
+	if cursor == "" || len(all) == 0 {
+		t.Fatalf("first chunk has %d items and cursor %q", len(all), cursor)
+	}
+	first := cursor
+	for cursor != "" {
+		var ids []int
+		ids, cursor = chunk(fetchMore(ctx, map[string]any{"cursor": cursor}))
+		all = append(all, ids...)
+	}
+	for i, id := range all {
+		if id != i+1 {
+			t.Fatalf("item %d has id %d; chunks skipped or repeated items", i, id)
+		}
+	}
+	if len(all) != 600 || requests != 1 {
+		t.Errorf("got %d items in %d requests, want 600 in 1", len(all), requests)
+	}
+
+	// New shaping starts again from the first item, and a small enough result
+	// comes back whole.
+	ids, cursor := chunk(fetchMore(ctx, map[string]any{"cursor": first, "fields": "records.id", "filter": "id:gt:590"}))
+	if len(ids) != 10 || ids[0] != 591 || cursor != "" {
+		t.Errorf("reshaped = %v, cursor %q", ids, cursor)
+	}
+
+	// Cursors belong to the client that fetched the response.
+	other := auth.NewContext(context.Background(), &auth.Client{Name: "laptop"})
+	for _, tt := range []struct {
+		ctx    context.Context
+		cursor string
+	}{{other, first}, {ctx, "0123456789ab"}} {
+		if res := fetchMore(tt.ctx, map[string]any{"cursor": tt.cursor}); !res.IsError {
+			t.Errorf("cursor %s was honoured: %s", tt.cursor, resultText(t, res))
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/policy"
 	"github.com/jakenesler/navigatorr/qbit"
 	"github.com/jakenesler/navigatorr/transmission"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -30,6 +32,11 @@ func callTool(t *testing.T, s *server.MCPServer, name string, args map[string]an
 	return res
 }
 
+// testGuard enforces the default policy.
+func testGuard(allowDestructive bool) *guard {
+	return newGuard(policy.New(config.PolicyConfig{}), allowDestructive, false, nil, NewState())
+}
+
 // Deletes in both torrent clients travel as POSTs rather than as the DELETE
 // verb, so the guard in call_api never sees them. The tools have to refuse on
 // their own, and they have to refuse before a request goes out — a guard that
@@ -57,8 +64,8 @@ func TestTorrentDeletesRespectAllowDestructive(t *testing.T) {
 			t.Cleanup(srv.Close)
 
 			s := server.NewMCPServer("test", "0.0.0")
-			registerQbitTools(s, qbit.NewClient(srv.URL, "u", "p"), false)
-			registerTransmissionTools(s, transmission.NewClient(srv.URL, "u", "p"), false)
+			registerQbitTools(s, qbit.NewClient(srv.URL, "u", "p"), testGuard(false))
+			registerTransmissionTools(s, transmission.NewClient(srv.URL, "u", "p"), testGuard(false))
 
 			res := callTool(t, s, tt.tool, tt.args)
 
@@ -89,8 +96,8 @@ func TestNonDestructiveActionsAreNotGated(t *testing.T) {
 			}))
 			t.Cleanup(srv.Close)
 
-			registerQbitTools(s, qbit.NewClient(srv.URL, "u", "p"), false)
-			registerTransmissionTools(s, transmission.NewClient(srv.URL, "u", "p"), false)
+			registerQbitTools(s, qbit.NewClient(srv.URL, "u", "p"), testGuard(false))
+			registerTransmissionTools(s, transmission.NewClient(srv.URL, "u", "p"), testGuard(false))
 
 			text := resultText(t, callTool(t, s, tt.tool, tt.args))
 			for _, refusal := range []string{"Deleting is disabled", "Removing is disabled"} {
@@ -112,7 +119,7 @@ func TestDeletesGoThroughWhenAllowed(t *testing.T) {
 	t.Cleanup(srv.Close)
 
 	s := server.NewMCPServer("test", "0.0.0")
-	registerQbitTools(s, qbit.NewClient(srv.URL, "u", "p"), true)
+	registerQbitTools(s, qbit.NewClient(srv.URL, "u", "p"), testGuard(true))
 
 	text := resultText(t, callTool(t, s, "qbit_manage_torrent", map[string]any{"action": "delete", "hashes": "abc"}))
 	if strings.Contains(text, "Deleting is disabled") {
@@ -122,3 +129,41 @@ func TestDeletesGoThroughWhenAllowed(t *testing.T) {
 		t.Error("delete was allowed but no request reached the server")
 	}
 }
+
+// Destructive intent in call_api often hides in a POST or PUT body rather
+// than in the DELETE verb. The default policy has to catch those too, and
+// refuse before the request reaches the service.
+func TestCallAPIPolicyCatchesNonDeleteDestructiveCalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		refused bool
+	}{
+		{"DELETE verb", map[string]any{"method": "DELETE", "path": "/series/1"}, true},
+		{"delete command", map[string]any{"method": "POST", "path": "/command", "body": `{"name":"DeleteSeries","seriesId":1}`}, true},
+		{"editor deleteFiles", map[string]any{"method": "PUT", "path": "/series/editor", "body": `{"seriesIds":[1,2],"deleteFiles":true}`}, true},
+		{"delete command, key case", map[string]any{"method": "POST", "path": "/command", "body": `{"Name":"DeleteSeries","seriesId":1}`}, true},
+		{"editor deleteFiles, key case", map[string]any{"method": "PUT", "path": "/series/editor", "body": `{"seriesIds":[1],"DeleteFiles":true}`}, true},
+		{"queue bulk", map[string]any{"method": "POST", "path": "/queue/bulk/remove", "body": `{"ids":[1]}`}, true},
+		{"dot segments", map[string]any{"method": "POST", "path": "/series/../command", "body": `{"name":"deleteseries"}`}, true},
+		{"refresh command", map[string]any{"method": "POST", "path": "/command", "body": `{"name":"RefreshSeries"}`}, false},
+		{"editor without deleteFiles", map[string]any{"method": "PUT", "path": "/series/editor", "body": `{"seriesIds":[1],"monitored":false}`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int
+			res := callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				hits++
+				w.Write([]byte(`{}`))
+			}, tt.args)
+
+			refused := strings.Contains(resultText(t, res), "is disabled")
+			if refused != tt.refused {
+				t.Fatalf("refused = %v, want %v: %s", refused, tt.refused, resultText(t, res))
+			}
+			if refused && hits != 0 {
+				t.Errorf("refused call still reached the service %d time(s)", hits)
+			}
+		})
+	}
+}
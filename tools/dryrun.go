@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/openapi"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// withDryRun adds the dry_run argument to a tool that sends requests.
+func withDryRun() mcp.ToolOption {
+	return mcp.WithBoolean("dry_run", mcp.Description("Validate the call and return the exact request it would send, and whether the policy would let it run, without contacting the service"))
+}
+
+// dryRunning reports whether a call should be rendered rather than sent. The
+// global dry_run setting cannot be switched off per call, so a demo stays
+// harmless whatever the model passes.
+func (g *guard) dryRunning(req mcp.CallToolRequest) bool {
+	return g.dryRun || mcp.ParseBoolean(req, "dry_run", false)
+}
+
+// dryRunResult is what a dry run returns in place of the call's result.
+type dryRunResult struct {
+	DryRun   bool            `json:"dry_run"`
+	Request  sentRequest     `json:"request"`
+	Endpoint *dryRunEndpoint `json:"endpoint,omitempty"` // call_api only
+	Policy   dryRunPolicy    `json:"policy"`
+}
+
+// sentRequest is an HTTP request as it would go on the wire, with credentials
+// masked.
+type sentRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    any               `json:"body,omitempty"`
+}
+
+// dryRunEndpoint is the OpenAPI operation a call_api request resolved to.
+type dryRunEndpoint struct {
+	Path    string `json:"path,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Warning string `json:"warning,omitempty"`
+}
+
+// dryRunPolicy is what the policy would do with the call.
+type dryRunPolicy struct {
+	Effect  policy.Effect `json:"effect"`
+	Reason  string        `json:"reason,omitempty"`
+	Outcome string        `json:"outcome"`
+}
+
+// render shows the request a tool would send, with the policy's verdict on
+// it. Nothing is sent, recorded or confirmed.
+func (g *guard) render(r policy.Request, what string, httpReq *http.Request, endpoint *dryRunEndpoint) *mcp.CallToolResult {
+	d, refusal := g.decide(httpReq.Context(), r, what)
+	outcome := refusal
+	switch {
+	case refusal != "":
+	case d.Effect == policy.Destructive && g.confirm:
+		outcome = "would return a preview and need confirming"
+	default:
+		outcome = "would run"
+	}
+
+	data, _ := json.MarshalIndent(dryRunResult{
+		DryRun:   true,
+		Request:  describeRequest(httpReq),
+		Endpoint: endpoint,
+		Policy:   dryRunPolicy{Effect: d.Effect, Reason: d.Reason, Outcome: outcome},
+	}, "", "  ")
+	return mcp.NewToolResultText(string(data))
+}
+
+// describeRequest renders req for a dry run. API keys ride in headers, basic
+// auth or the query string depending on the service, so all three are
+// masked; so are secrets in the body.
+func describeRequest(req *http.Request) sentRequest {
+	u := *req.URL
+	q := u.Query()
+	for k := range q {
+		if audit.Sensitive(k) {
+			q.Set(k, audit.Mask)
+		}
+	}
+	u.RawQuery = strings.ReplaceAll(q.Encode(), url.QueryEscape(audit.Mask), audit.Mask)
+
+	sent := sentRequest{Method: req.Method, URL: u.String()}
+	if len(req.Header) > 0 {
+		sent.Headers = make(map[string]string, len(req.Header))
+		for k := range req.Header {
+			v := req.Header.Get(k)
+			switch {
+			case k == "Authorization":
+				scheme, _, _ := strings.Cut(v, " ")
+				v = scheme + " " + audit.Mask
+			case audit.Sensitive(k):
+				v = audit.Mask
+			}
+			sent.Headers[k] = v
+		}
+	}
+
+	if req.GetBody == nil {
+		return sent
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return sent
+	}
+	defer rc.Close()
+	raw, _ := io.ReadAll(rc)
+	switch req.Header.Get("Content-Type") {
+	case "application/json":
+		var decoded any
+		if json.Unmarshal(raw, &decoded) == nil {
+			sent.Body = audit.Redact(decoded)
+			return sent
+		}
+	case "application/x-www-form-urlencoded":
+		if form, err := url.ParseQuery(string(raw)); err == nil {
+			fields := make(map[string]any, len(form))
+			for k := range form {
+				fields[k] = form.Get(k)
+			}
+			sent.Body = audit.Redact(fields)
+			return sent
+		}
+	}
+	if len(raw) > 0 {
+		sent.Body = string(raw)
+	}
+	return sent
+}
+
+// matchEndpoint finds the spec operation for a call_api request without
+// validating it, or nil when there is no spec or no match.
+func matchEndpoint(idx *openapi.Index, prefix, path, method string) *openapi.EndpointDetail {
+	if idx == nil {
+		return nil
+	}
+	clean := strings.SplitN(path, "?", 2)[0]
+	for _, p := range []string{prefix + clean, clean} {
+		if detail, ok := idx.Match(p, method); ok {
+			return detail
+		}
+	}
+	return nil
+}
+
+// resolveEndpoint checks a call_api request against the service's spec and
+// finds the operation it calls. A request path may or may not repeat the API
+// version prefix the spec's paths carry, so both spellings are tried. The
+// endpoint is always returned for a dry run to show, with any problem as its
+// warning; the error is set only when the spec rejects the request.
+func resolveEndpoint(ctx context.Context, idx *openapi.Index, service, prefix, path string, httpReq *http.Request) (*dryRunEndpoint, error) {
+	if idx == nil {
+		return &dryRunEndpoint{Warning: "no OpenAPI spec is loaded for " + service + ", so the request was not checked"}, nil
+	}
+	clean := strings.SplitN(path, "?", 2)[0]
+	detail, err := idx.Validate(ctx, httpReq, prefix+clean, clean)
+	endpoint := &dryRunEndpoint{}
+	if detail != nil {
+		endpoint.Path, endpoint.Summary = detail.Path, detail.Summary
+	}
+	if err != nil {
+		endpoint.Warning = err.Error()
+	}
+	return endpoint, err
+}
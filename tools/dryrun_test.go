@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/openapi"
+	"github.com/jakenesler/navigatorr/qbit"
+	"github.com/jakenesler/navigatorr/sabnzbd"
+	"github.com/jakenesler/navigatorr/transmission"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// neverCalled fails the test if a dry run reaches the service.
+func neverCalled(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run sent %s %s", r.Method, r.URL)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func parseDryRun(t *testing.T, res *mcp.CallToolResult) dryRunResult {
+	t.Helper()
+	var out dryRunResult
+	if err := json.Unmarshal([]byte(resultText(t, res)), &out); err != nil || !out.DryRun {
+		t.Fatalf("not a dry run result (%v):\n%s", err, resultText(t, res))
+	}
+	return out
+}
+
+func TestCallAPIDryRun(t *testing.T) {
+	srv := neverCalled(t)
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "secret-key", AuthMethod: "query", APIVersion: "/api/v3"},
+	}})
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: map[string]any{
+		"service": "sonarr", "method": "delete", "path": "/series/12",
+		"query": `{"deleteFiles":true}`, "dry_run": true,
+	}}}
+	res, err := handleCallAPI(context.Background(), req, registry, nil, 12800, testGuard(false))
+	if err != nil || res.IsError {
+		t.Fatalf("dry run failed: %v %s", err, resultText(t, res))
+	}
+
+	out := parseDryRun(t, res)
+	if out.Request.Method != "DELETE" || !strings.HasPrefix(out.Request.URL, srv.URL+"/api/v3/series/12?") {
+		t.Errorf("request = %+v", out.Request)
+	}
+	if !strings.Contains(out.Request.URL, "deleteFiles=true") || !strings.Contains(out.Request.URL, "apikey="+audit.Mask) {
+		t.Errorf("URL should carry the query with the key masked: %s", out.Request.URL)
+	}
+	if strings.Contains(resultText(t, res), "secret-key") {
+		t.Error("dry run leaked the API key")
+	}
+	if out.Policy.Effect != "destructive" || !strings.Contains(out.Policy.Outcome, "disabled") {
+		t.Errorf("policy = %+v, want the refusal a real call would get", out.Policy)
+	}
+	if out.Endpoint == nil || out.Endpoint.Warning == "" {
+		t.Errorf("without a spec the endpoint should carry a warning: %+v", out.Endpoint)
+	}
+}
+
+func TestResolveEndpoint(t *testing.T) {
+	idx := &openapi.Index{Service: "sonarr", Endpoints: map[string]map[string]*openapi.EndpointDetail{
+		"/api/v3/series/{id}": {
+			"GET": {Path: "/api/v3/series/{id}", Method: "GET", Summary: "Get series"},
+			"PUT": {Path: "/api/v3/series/{id}", Method: "PUT", Summary: "Update series"},
+		},
+	}}
+	resolve := func(method, path string) (*dryRunEndpoint, error) {
+		return resolveEndpoint(context.Background(), idx, "sonarr", "/api/v3", path, httptest.NewRequest(method, "http://sonarr/api/v3"+path, nil))
+	}
+	if got, err := resolve("PUT", "/series/5"); err != nil || got.Path != "/api/v3/series/{id}" || got.Summary != "Update series" {
+		t.Errorf("PUT /series/5 resolved to %+v, %v", got, err)
+	}
+	if got, err := resolve("POST", "/series/5"); err == nil || !strings.Contains(got.Warning, "GET, PUT") {
+		t.Errorf("an unsupported method should list the supported ones: %+v", got)
+	}
+	if got, err := resolve("GET", "/movies"); err == nil || got.Warning == "" || got.Path != "" {
+		t.Errorf("an unknown path should warn: %+v", got)
+	}
+	if got, err := resolveEndpoint(context.Background(), nil, "sonarr", "/api/v3", "/movies", nil); err != nil || got.Warning == "" {
+		t.Errorf("without a spec the request should pass with a warning: %+v, %v", got, err)
+	}
+}
+
+// The download clients render their own wire formats: an RPC body, a form,
+// and a mode query.
+func TestDownloadClientDryRuns(t *testing.T) {
+	srv := neverCalled(t)
+	g := testGuard(true)
+
+	s := server.NewMCPServer("test", "0.0.0")
+	registerTransmissionTools(s, transmission.NewClient(srv.URL, "u", "p"), g)
+	registerQbitTools(s, qbit.NewClient(srv.URL, "u", "p"), g)
+	registerSabnzbdTools(s, sabnzbd.NewClient(srv.URL, "", "sab-key"), g)
+
+	out := parseDryRun(t, callTool(t, s, "transmission_manage_torrent", map[string]any{"action": "remove_data", "ids": "3,4", "dry_run": true}))
+	body, _ := json.Marshal(out.Request.Body)
+	if string(body) != `{"arguments":{"delete-local-data":true,"ids":[3,4]},"method":"torrent-remove"}` {
+		t.Errorf("transmission body = %s", body)
+	}
+	if out.Request.Headers["Authorization"] != "Basic "+audit.Mask {
+		t.Errorf("basic auth should be masked: %v", out.Request.Headers)
+	}
+
+	out = parseDryRun(t, callTool(t, s, "qbit_manage_torrent", map[string]any{"action": "delete_files", "hashes": "abc,def", "dry_run": true}))
+	form, _ := out.Request.Body.(map[string]any)
+	if out.Request.URL != srv.URL+"/api/v2/torrents/delete" || form["hashes"] != "abc|def" || form["deleteFiles"] != "true" {
+		t.Errorf("qbit request = %+v", out.Request)
+	}
+	if out.Policy.Outcome != "would run" {
+		t.Errorf("allowed delete outcome = %q", out.Policy.Outcome)
+	}
+
+	g.dryRun = true // the global switch needs no argument
+	out = parseDryRun(t, callTool(t, s, "sabnzbd_manage_item", map[string]any{"action": "priority", "nzo_id": "SABnzbd_nzo_1", "value": "high"}))
+	for _, want := range []string{"mode=queue", "name=priority", "value=SABnzbd_nzo_1", "value2=1", "apikey=" + audit.Mask} {
+		if !strings.Contains(out.Request.URL, want) {
+			t.Errorf("SABnzbd URL %s is missing %s", out.Request.URL, want)
+		}
+	}
+}
@@ -0,0 +1,602 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A filter is a boolean expression over the items of an array response.
+// Predicates take the form field:op:value and combine with and, or, not and
+// parentheses:
+//
+//	title:contains:pirates and (year:gte:2000 or not hasFile:eq:true)
+//	seasons[].monitored:eq:false     some season is unmonitored
+//	seasons[all].monitored:eq:true   every season is monitored
+//	airDateUtc:after:-7d             aired in the last week
+//	statistics.sizeOnDisk:gt:10GB
+//
+// A value runs to the next and, or or closing parenthesis, so titles with
+// spaces need no quoting unless they contain one of those; "quoted" values
+// are taken literally.
+type filterExpr interface {
+	match(item any) bool
+}
+
+type andExpr []filterExpr
+
+func (e andExpr) match(item any) bool {
+	for _, sub := range e {
+		if !sub.match(item) {
+			return false
+		}
+	}
+	return true
+}
+
+type orExpr []filterExpr
+
+func (e orExpr) match(item any) bool {
+	for _, sub := range e {
+		if sub.match(item) {
+			return true
+		}
+	}
+	return false
+}
+
+type notExpr struct{ filterExpr }
+
+func (e notExpr) match(item any) bool { return !e.filterExpr.match(item) }
+
+// filterOps lists the operators in the order the parse error names them.
+var filterOps = []string{"eq", "ne", "contains", "startswith", "endswith", "in", "regex", "exists", "gt", "gte", "lt", "lte", "before", "after"}
+
+// quantifier says how a path segment holding an array is matched. Arrays are
+// matched as any element unless [all] says otherwise.
+type quantifier int
+
+const (
+	quantAny quantifier = iota
+	quantAll
+)
+
+type pathSeg struct {
+	key   string
+	quant quantifier
+}
+
+// predicate is one field:op:value test. ne and exists:false are stored as
+// the negation of eq and exists, so an item without the field is "not
+// equal", as it always was, and an array is "not equal" only when no element
+// equals the value.
+type predicate struct {
+	path   []pathSeg
+	op     string
+	negate bool
+
+	text   string   // lower-cased value for the string ops
+	values []string // lower-cased values for in
+	num    float64
+	isNum  bool
+	date   time.Time
+	isDate bool
+	re     *regexp.Regexp
+}
+
+func (p *predicate) match(item any) bool {
+	return p.eval(item, p.path) != p.negate
+}
+
+func (p *predicate) eval(v any, path []pathSeg) bool {
+	if len(path) == 0 {
+		// exists looks at the field itself; every other op at a scalar, or
+		// at any element of an array of scalars.
+		if arr, ok := v.([]any); ok && p.op != "exists" {
+			for _, el := range arr {
+				if p.test(el) {
+					return true
+				}
+			}
+			return false
+		}
+		return p.test(v)
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return p.test(nil)
+	}
+	seg, rest := path[0], path[1:]
+	child := obj[seg.key]
+	arr, isArr := child.([]any)
+	switch {
+	case !isArr || len(rest) == 0 && seg.quant == quantAny:
+		return p.eval(child, rest)
+	case seg.quant == quantAll:
+		for _, el := range arr {
+			if !p.eval(el, rest) {
+				return false
+			}
+		}
+		return true
+	default:
+		for _, el := range arr {
+			if p.eval(el, rest) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// test applies the operator to one value. A missing value passes nothing,
+// not even a negated test's inner check.
+func (p *predicate) test(v any) bool {
+	if v == nil {
+		return false
+	}
+	if p.op == "exists" {
+		return true
+	}
+	s := formatValue(v)
+	lower := strings.ToLower(s)
+	switch p.op {
+	case "eq":
+		if f, ok := v.(float64); ok && p.isNum {
+			return f == p.num
+		}
+		return lower == p.text
+	case "contains":
+		return strings.Contains(lower, p.text)
+	case "startswith":
+		return strings.HasPrefix(lower, p.text)
+	case "endswith":
+		return strings.HasSuffix(lower, p.text)
+	case "in":
+		for _, want := range p.values {
+			if lower == want {
+				return true
+			}
+		}
+		return false
+	case "regex":
+		return p.re.MatchString(s)
+	}
+
+	// The ordering ops compare numbers when the value was a number or size,
+	// and dates otherwise.
+	var cmp int
+	if p.isNum {
+		f, ok := v.(float64)
+		if !ok {
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return false
+			}
+		}
+		cmp = compareFloat(f, p.num)
+	} else {
+		t, ok := parseDate(s, time.Time{})
+		if !ok {
+			return false
+		}
+		cmp = t.Compare(p.date)
+	}
+	switch p.op {
+	case "gt", "after":
+		return cmp > 0
+	case "gte":
+		return cmp >= 0
+	case "lt", "before":
+		return cmp < 0
+	case "lte":
+		return cmp <= 0
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// formatValue spells a decoded JSON value the way it would be typed in a
+// filter: numbers without exponents, so a size of 12000000000 matches as
+// written.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// applyFilter keeps the items of arr that match f. Items that are not
+// objects never match.
+func applyFilter(arr []any, f filterExpr) []any {
+	// Non-nil so a filter that matches nothing marshals as [] rather than null.
+	result := make([]any, 0, len(arr))
+	for _, item := range arr {
+		if _, ok := item.(map[string]any); ok && f.match(item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// parseFilter compiles a filter expression. An empty string is no filter.
+func parseFilter(s string) (filterExpr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	p := &filterParser{src: s, toks: tokenizeFilter(s)}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.start+1)
+	}
+	return e, nil
+}
+
+type filterToken struct {
+	text       string
+	start, end int
+}
+
+// tokenizeFilter splits on whitespace, keeping "quoted" runs whole and
+// splitting off leading ( and trailing ) as tokens of their own. Only as many
+// trailing parentheses are split off as there are groups open, so a regex
+// such as ^(a|b) keeps its own.
+func tokenizeFilter(s string) []filterToken {
+	var toks []filterToken
+	depth := 0
+	i := 0
+	for i < len(s) {
+		if s[i] == ' ' || s[i] == '\t' || s[i] == '\n' {
+			i++
+			continue
+		}
+		if s[i] == '(' {
+			toks = append(toks, filterToken{"(", i, i + 1})
+			depth++
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && s[i] != ' ' && s[i] != '\t' && s[i] != '\n' {
+			if s[i] == '"' {
+				for i++; i < len(s) && s[i] != '"'; i++ {
+					if s[i] == '\\' {
+						i++
+					}
+				}
+			}
+			if i < len(s) {
+				i++
+			}
+		}
+		end := i
+		for end > start && s[end-1] == ')' && depth > 0 {
+			end--
+			depth--
+		}
+		if end > start {
+			toks = append(toks, filterToken{s[start:end], start, end})
+		}
+		for j := end; j < i; j++ {
+			toks = append(toks, filterToken{")", j, j + 1})
+		}
+	}
+	return toks
+}
+
+type filterParser struct {
+	src  string
+	toks []filterToken
+	pos  int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.toks) {
+		return filterToken{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+// accept consumes the next token if it is one of words, ignoring case.
+func (p *filterParser) accept(words ...string) bool {
+	tok, ok := p.peek()
+	if !ok {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(tok.text, w) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	var terms orExpr
+	for {
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, e)
+		if !p.accept("or", "||") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	var terms andExpr
+	for {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, e)
+		if !p.accept("and", "&&") {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *filterParser) parseUnary() (filterExpr, error) {
+	if p.accept("not", "!") {
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+	if p.accept("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ) at position %d", p.position())
+		}
+		return e, nil
+	}
+	return p.parsePredicate()
+}
+
+// position is the 1-based offset of the next token, or just past the end.
+func (p *filterParser) position() int {
+	if tok, ok := p.peek(); ok {
+		return tok.start + 1
+	}
+	return len(p.src) + 1
+}
+
+// isBoundary reports whether a token ends a predicate's value.
+func isBoundary(tok filterToken) bool {
+	switch strings.ToLower(tok.text) {
+	case "and", "or", "&&", "||", ")":
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parsePredicate() (filterExpr, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("expected field:op:value at the end of the filter")
+	}
+	if isBoundary(tok) {
+		return nil, fmt.Errorf("expected field:op:value at position %d, got %q", tok.start+1, tok.text)
+	}
+	p.pos++
+	parts := strings.SplitN(tok.text, ":", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%q at position %d is not field:op:value", tok.text, tok.start+1)
+	}
+
+	// The value runs on across spaces to the next boundary.
+	end := tok.end
+	for {
+		next, ok := p.peek()
+		if !ok || isBoundary(next) {
+			break
+		}
+		end = next.end
+		p.pos++
+	}
+	value := ""
+	if len(parts) == 3 {
+		value = strings.TrimSpace(p.src[tok.start+len(parts[0])+len(parts[1])+2 : end])
+	} else if end != tok.end {
+		return nil, fmt.Errorf("%q at position %d is not field:op:value", p.src[tok.start:end], tok.start+1)
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, `"`) {
+		value = unquoted
+	}
+
+	pred, err := newPredicate(parts[0], strings.ToLower(parts[1]), value)
+	if err != nil {
+		return nil, fmt.Errorf("%s (at position %d)", err, tok.start+1)
+	}
+	return pred, nil
+}
+
+func newPredicate(field, op, value string) (*predicate, error) {
+	path, err := parseFilterPath(field)
+	if err != nil {
+		return nil, err
+	}
+	p := &predicate{path: path, op: op, text: strings.ToLower(value)}
+	needsValue := func() error {
+		if value == "" {
+			return fmt.Errorf("%s:%s needs a value", field, op)
+		}
+		return nil
+	}
+
+	switch op {
+	case "ne":
+		p.op, p.negate = "eq", true
+		fallthrough
+	case "eq":
+		p.num, p.isNum = parseNumber(value)
+	case "contains", "startswith", "endswith":
+		if err := needsValue(); err != nil {
+			return nil, err
+		}
+	case "in":
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				p.values = append(p.values, strings.ToLower(v))
+			}
+		}
+		if len(p.values) == 0 {
+			return nil, fmt.Errorf("%s:in needs a comma-separated list of values", field)
+		}
+	case "regex":
+		if err := needsValue(); err != nil {
+			return nil, err
+		}
+		// Case-insensitive, like every other string op.
+		if p.re, err = regexp.Compile("(?i)" + value); err != nil {
+			return nil, fmt.Errorf("%s:regex: %v", field, err)
+		}
+	case "exists":
+		switch strings.ToLower(value) {
+		case "", "true":
+		case "false":
+			p.negate = true
+		default:
+			return nil, fmt.Errorf("%s:exists takes true or false, not %q", field, value)
+		}
+	case "gt", "gte", "lt", "lte":
+		if p.num, p.isNum = parseSize(value); p.isNum {
+			break
+		}
+		fallthrough
+	case "before", "after":
+		if p.date, p.isDate = parseDate(value, time.Now()); !p.isDate {
+			want := "a date (2024-01-31, RFC 3339, or relative like -7d)"
+			if op != "before" && op != "after" {
+				want = "a number, a size (10GB) or " + want
+			}
+			return nil, fmt.Errorf("%s:%s needs %s, not %q", field, op, want, value)
+		}
+	default:
+		return nil, fmt.Errorf("unknown op %q in %s:%s (use: %s)", op, field, op, strings.Join(filterOps, ", "))
+	}
+	return p, nil
+}
+
+// parseFilterPath splits a dotted field path, reading [] or [any] and [all]
+// suffixes as quantifiers over array fields.
+func parseFilterPath(field string) ([]pathSeg, error) {
+	if field == "" {
+		return nil, fmt.Errorf("missing field name")
+	}
+	var path []pathSeg
+	for _, part := range strings.Split(field, ".") {
+		seg := pathSeg{key: part}
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			seg.key = part[:i]
+			switch part[i:] {
+			case "[]", "[any]":
+			case "[all]":
+				seg.quant = quantAll
+			default:
+				return nil, fmt.Errorf("field %q: use [], [any] or [all] after an array field", field)
+			}
+		}
+		if seg.key == "" {
+			return nil, fmt.Errorf("field %q has an empty segment", field)
+		}
+		path = append(path, seg)
+	}
+	return path, nil
+}
+
+func parseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+var sizePattern = regexp.MustCompile(`(?i)^([0-9]*\.?[0-9]+)\s*(b|kb|mb|gb|tb|kib|mib|gib|tib)$`)
+
+// parseSize reads a number, or a size such as 1.5GB in powers of 1024 as
+// the *arr apps report sizes in bytes.
+func parseSize(s string) (float64, bool) {
+	if f, ok := parseNumber(s); ok {
+		return f, true
+	}
+	m := sizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	f, _ := strconv.ParseFloat(m[1], 64)
+	exp := strings.Index("bkmgt", strings.ToLower(m[2][:1]))
+	return f * math.Pow(1024, float64(exp)), true
+}
+
+var relativeDate = regexp.MustCompile(`^([+-])(\d+)([hdwy])$`)
+
+// parseDate reads RFC 3339 timestamps, bare dates and times as UTC, and,
+// when now is set, relative dates such as -7d or +12h and the words now and
+// today.
+func parseDate(s string, now time.Time) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	if now.IsZero() {
+		return time.Time{}, false
+	}
+	switch strings.ToLower(s) {
+	case "now":
+		return now, true
+	case "today":
+		y, m, d := now.UTC().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), true
+	}
+	m := relativeDate.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return time.Time{}, false
+	}
+	n, _ := strconv.Atoi(m[2])
+	if m[1] == "-" {
+		n = -n
+	}
+	switch m[3] {
+	case "h":
+		return now.Add(time.Duration(n) * time.Hour), true
+	case "d":
+		return now.AddDate(0, 0, n), true
+	case "w":
+		return now.AddDate(0, 0, 7*n), true
+	}
+	return now.AddDate(n, 0, 0), true
+}
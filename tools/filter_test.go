@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyFilter(t *testing.T) {
+	recent := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	items := []any{
+		map[string]any{"title": "Alpha", "year": float64(2001), "hasFile": true, "album": "Greatest Hits Vol 2",
+			"size": float64(1 << 20), "added": "2023-06-01T00:00:00Z",
+			"seasons": []any{map[string]any{"monitored": true}, map[string]any{"monitored": false}}},
+		map[string]any{"title": "Beta", "year": float64(2020), "hasFile": false, "size": float64(2 << 30), "added": recent,
+			"seasons": []any{map[string]any{"monitored": true}}, "tags": []any{float64(3), float64(4)}},
+		map[string]any{"title": "Gamma", "year": float64(2015), "album": "Hits and Misses", "seasons": []any{}}, // hasFile absent
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{"contains is case-insensitive", "title:contains:alp", []string{"Alpha"}},
+		{"gt compares numerically", "year:gt:2010", []string{"Beta", "Gamma"}},
+		{"lt compares numerically", "year:lt:2010", []string{"Alpha"}},
+		{"eq matches", "hasFile:eq:true", []string{"Alpha"}},
+		{"ne includes items missing the field", "hasFile:ne:true", []string{"Beta", "Gamma"}},
+		{"no matches yields empty, not null", "title:contains:zzz", []string{}},
+		{"and, or and parentheses", "year:gt:2010 and (title:eq:beta or hasFile:exists:false)", []string{"Beta", "Gamma"}},
+		{"not", "not title:startswith:a", []string{"Beta", "Gamma"}},
+		{"in", "title:in:alpha, gamma", []string{"Alpha", "Gamma"}},
+		{"regex is case-insensitive", "title:regex:^(a|g)", []string{"Alpha", "Gamma"}},
+		{"values run across spaces", "album:eq:Greatest Hits Vol 2 or year:eq:2020", []string{"Alpha", "Beta"}},
+		{"quoted values keep keywords", `album:contains:"hits and"`, []string{"Gamma"}},
+		{"sizes", "size:gte:1.5GB", []string{"Beta"}},
+		{"absolute dates", "added:before:2024-01-01", []string{"Alpha"}},
+		{"relative dates", "added:after:-7d", []string{"Beta"}},
+		{"any element of a nested array", "seasons[].monitored:eq:false", []string{"Alpha"}},
+		{"every element of a nested array", "seasons[all].monitored:eq:true", []string{"Beta", "Gamma"}},
+		{"arrays of scalars match any element", "tags:eq:4", []string{"Beta"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("parseFilter(%q): %v", tt.filter, err)
+			}
+			got := applyFilter(items, f)
+			if got == nil {
+				t.Fatal("applyFilter returned nil; it must marshal as [] not null")
+			}
+			var titles []string
+			for _, it := range got {
+				titles = append(titles, it.(map[string]any)["title"].(string))
+			}
+			if len(titles) != len(tt.want) {
+				t.Fatalf("got %v, want %v", titles, tt.want)
+			}
+			for i := range titles {
+				if titles[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", titles, tt.want)
+				}
+			}
+
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) == "null" {
+				t.Error("empty filter result marshaled to null")
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{"title", "not field:op:value"},
+		{"title:like:x", `unknown op "like"`},
+		{"year:gt:soon", "needs a number, a size (10GB) or a date"},
+		{"added:before:yesterday", "needs a date"},
+		{"title:regex:(", "regex"},
+		{"title:eq:a and", "expected field:op:value at the end"},
+		{"(title:eq:a", "missing )"},
+		{"title:eq:a )", `unexpected ")"`},
+		{"seasons[x].monitored:eq:true", "[all]"},
+		{"title:in:", "comma-separated"},
+	}
+	for _, tt := range tests {
+		_, err := parseFilter(tt.filter)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseFilter(%q) = %v, want an error containing %q", tt.filter, err, tt.want)
+		}
+	}
+}
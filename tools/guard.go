@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/internal"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// guard applies the policy to a call before any request goes out, and
+// records the call in the audit log once it has run. Every tool that changes
+// state goes through it, so a rule written once holds for call_api and the
+// download client tools alike.
+type guard struct {
+	policy           *policy.Policy
+	allowDestructive bool
+	confirm          bool // destructive calls need a preview and confirmation first
+	dryRun           bool // every call is a dry run, whatever its arguments say
+	pending          *confirmations
+	results          *resultStore // oversized responses fetch_more pages through
+	audit            *audit.Log
+}
+
+// newGuard returns a guard keeping its confirmation tokens and stored results
+// in state, which outlives the guard across reloads.
+func newGuard(pol *policy.Policy, allowDestructive, confirm bool, auditLog *audit.Log, state *State) *guard {
+	return &guard{policy: pol, allowDestructive: allowDestructive, confirm: confirm, pending: state.pending, results: state.results, audit: auditLog}
+}
+
+// check returns a refusal when the policy denies r, or marks it destructive
+// while the caller may not run destructive calls. With confirm_destructive on
+// it returns a preview instead, until the call is confirmed. A nil result
+// means go ahead. what names the action in the refusal, e.g. "Deleting", and
+// preview, which may be nil, lists what the call would affect.
+func (g *guard) check(ctx context.Context, req mcp.CallToolRequest, r policy.Request, what string, preview func(context.Context) any) *mcp.CallToolResult {
+	d, refusal := g.decide(ctx, r, what)
+	if refusal != "" {
+		if d.Effect == policy.Deny {
+			internal.ErrorContextf(ctx, "policy denied %s %s on %s: %s", r.Method, r.Path, r.Service, d.Reason)
+		}
+		return mcp.NewToolResultError(refusal)
+	}
+	if d.Effect == policy.Destructive && g.confirm {
+		return g.confirmCall(ctx, req, what, d.Reason, preview)
+	}
+	return nil
+}
+
+// decide evaluates the policy for r and returns the refusal the caller would
+// get, or "" when the call may run.
+func (g *guard) decide(ctx context.Context, r policy.Request, what string) (policy.Decision, string) {
+	d := g.policy.Evaluate(r)
+	switch d.Effect {
+	case policy.Deny:
+		return d, fmt.Sprintf("%s is denied by policy (%s).", what, d.Reason)
+	case policy.Destructive:
+		if !destructiveAllowed(ctx, g.allowDestructive) {
+			return d, fmt.Sprintf("%s is disabled (%s). Set allow_destructive: true in config.yaml to enable.", what, d.Reason)
+		}
+	}
+	return d, ""
+}
+
+// confirmCall runs the second phase of a destructive call: it lets a call
+// carrying a valid token through, asks the human when the client supports
+// elicitation, and otherwise hands back a preview and a fresh token.
+func (g *guard) confirmCall(ctx context.Context, req mcp.CallToolRequest, what, reason string, preview func(context.Context) any) *mcp.CallToolResult {
+	// Outside an MCP session, such as from the command line, a token could
+	// never be redeemed: the process that issued it is gone by the next call.
+	// The human there is asked in place.
+	if ask := confirmerFrom(ctx); ask != nil {
+		var affects any
+		if preview != nil {
+			affects = preview(ctx)
+		}
+		if !ask(previewMessage(what, reason, affects)) {
+			internal.LogContextf(ctx, "not confirmed: %s", what)
+			return mcp.NewToolResultError(what + " was not confirmed. Nothing was changed.")
+		}
+		internal.LogContextf(ctx, "confirmed in place: %s", what)
+		return nil
+	}
+
+	fingerprint := callFingerprint(ctx, req)
+	if token := mcp.ParseString(req, "confirm", ""); token != "" {
+		if g.pending.redeem(token, fingerprint) {
+			internal.LogContextf(ctx, "confirmed %s", what)
+			return nil
+		}
+		return mcp.NewToolResultError("Confirmation token is invalid, expired, already used, or was issued for a different call. Repeat the call without confirm to get a new preview.")
+	}
+
+	var affects any
+	if preview != nil {
+		affects = preview(ctx)
+	}
+
+	if accepted, answered := elicitConfirmation(ctx, previewMessage(what, reason, affects)); answered {
+		if !accepted {
+			internal.LogContextf(ctx, "user declined %s", what)
+			return mcp.NewToolResultError(what + " was declined by the user.")
+		}
+		internal.LogContextf(ctx, "user confirmed %s", what)
+		return nil
+	}
+
+	data, _ := json.MarshalIndent(confirmPreview{
+		ConfirmationRequired: true,
+		Action:               what,
+		Reason:               reason,
+		Affects:              affects,
+		Confirm:              g.pending.issue(fingerprint),
+		ExpiresIn:            confirmTTL.String(),
+		Instructions:         "Nothing has been changed. Show this to the user, and if they agree, repeat the same call with confirm set to this token.",
+	}, "", "  ")
+	return mcp.NewToolResultText(string(data))
+}
+
+// record writes a call that ran to the audit log and returns its entry ID.
+// status is the HTTP status where the tool sees one, and 0 otherwise;
+// snapshots hold the state the call replaced. A failed write is logged rather
+// than failing a call that already happened.
+func (g *guard) record(ctx context.Context, req mcp.CallToolRequest, r policy.Request, start time.Time, status int, callErr error, snapshots []audit.Snapshot) string {
+	return g.write(ctx, entryFor(ctx, req, r, start, status, callErr, snapshots))
+}
+
+func entryFor(ctx context.Context, req mcp.CallToolRequest, r policy.Request, start time.Time, status int, callErr error, snapshots []audit.Snapshot) audit.Entry {
+	e := audit.Entry{
+		Time:       start,
+		Client:     internal.ClientName(ctx),
+		Tool:       req.Params.Name,
+		Service:    r.Service,
+		Method:     r.Method,
+		Path:       r.Path,
+		Template:   r.Template,
+		Query:      r.Query,
+		Body:       r.Body,
+		Status:     status,
+		DurationMS: time.Since(start).Milliseconds(),
+		Snapshots:  snapshots,
+	}
+	if callErr != nil {
+		e.Error = callErr.Error()
+	}
+	return e
+}
+
+func (g *guard) write(ctx context.Context, e audit.Entry) string {
+	id, err := g.audit.Record(e)
+	if err != nil {
+		internal.ErrorContextf(ctx, "audit: %v", err)
+	}
+	return id
+}
@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jakenesler/navigatorr/format"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// output is how a result is written: the format, and for a table or CSV the
+// columns, in order.
+type output struct {
+	format  format.Format
+	columns []string
+}
+
+// render writes v in the output's format. It cannot fail for decoded JSON;
+// should it anyway, compact JSON is the fallback.
+func (o output) render(v any) string {
+	text, err := format.Render(v, o.format, o.columns)
+	if err != nil {
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return text
+}
+
+// withOutput adds the output argument, defaulting to def.
+func withOutput(def format.Format) mcp.ToolOption {
+	return mcp.WithString("output", mcp.Description(fmt.Sprintf("Output format: %s (default %s). table is a Markdown table of the list, smaller and easier to read than JSON; csv is the same rows as CSV", format.Names, def)))
+}
+
+// withColumns adds the columns argument, for tools without fields of their
+// own.
+func withColumns() mcp.ToolOption {
+	return mcp.WithString("columns", mcp.Description("Comma-separated columns for table and csv output, in order (e.g. \"name,progress,eta\"). Nested fields use dot notation"))
+}
+
+// parseOutput reads the output and columns arguments.
+func parseOutput(req mcp.CallToolRequest, def format.Format) (output, error) {
+	f, err := format.Parse(mcp.ParseString(req, "output", ""), def)
+	if err != nil {
+		return output{}, err
+	}
+	return output{format: f, columns: parseFields(mcp.ParseString(req, "columns", ""))}, nil
+}
+
+// outputResult renders a list tool's result as the call asks.
+func outputResult(req mcp.CallToolRequest, v any) *mcp.CallToolResult {
+	out, err := parseOutput(req, format.JSON)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	return mcp.NewToolResultText(out.render(v))
+}
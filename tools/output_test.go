@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/sabnzbd"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestCallAPIOutputFormats(t *testing.T) {
+	queue := `{"page": 1, "totalRecords": 2, "records": [
+	  {"title": "Andor", "status": "downloading", "sizeleft": 1024},
+	  {"title": "Severance", "status": "queued", "sizeleft": 0}
+	]}`
+	serve := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(queue)) }
+
+	for _, tt := range []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"output": "table", "fields": "page,totalRecords,records.title,records.status"},
+			"page: 1\ntotalRecords: 2\n\n| title | status |\n| --- | --- |\n| Andor | downloading |\n| Severance | queued |"},
+		{map[string]any{"output": "csv", "fields": "records.status,records.title", "filter": "sizeleft:gt:0"},
+			"status,title\ndownloading,Andor"},
+		{map[string]any{"output": "yaml", "expr": "records[0].{t: title}"}, "t: Andor"},
+		{map[string]any{"group_by": "status", "output": "table", "sort": "status"},
+			"page: 1\ntotalRecords: 2\n\n| count | status |\n| --- | --- |\n| 1 | downloading |\n| 1 | queued |"},
+	} {
+		tt.args["path"] = "/queue"
+		if got := resultText(t, callAPI(t, serve, tt.args)); got != tt.want {
+			t.Errorf("%v:\ngot\n%s\nwant\n%s", tt.args, got, tt.want)
+		}
+	}
+
+	res := callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a bad output reached the service")
+	}, map[string]any{"path": "/queue", "output": "xml"})
+	if !res.IsError || !strings.Contains(resultText(t, res), "compact_json") {
+		t.Errorf("bad output = %s", resultText(t, res))
+	}
+}
+
+func TestListToolOutput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"queue": {"paused": false, "speed": "1.2 M", "slots": [
+		  {"nzo_id": "SABnzbd_nzo_1", "filename": "Andor.S01E01", "percentage": "40", "timeleft": "0:10:00"}
+		]}}`))
+	}))
+	t.Cleanup(srv.Close)
+	s := server.NewMCPServer("test", "0.0.0")
+	registerSabnzbdTools(s, sabnzbd.NewClient(srv.URL, "", "k"), testGuard(false))
+
+	got := resultText(t, callTool(t, s, "sabnzbd_list_queue", map[string]any{"output": "table", "columns": "filename,percentage,timeleft"}))
+	want := "| filename | percentage | timeleft |\n| --- | --- | --- |\n| Andor.S01E01 | 40 | 0:10:00 |"
+	if !strings.Contains(got, "speed: 1.2 M") || !strings.HasSuffix(got, want) {
+		t.Errorf("got\n%s", got)
+	}
+	if got := resultText(t, callTool(t, s, "sabnzbd_list_queue", nil)); !strings.Contains(got, "\n  \"slots\": [") {
+		t.Errorf("default output should stay indented JSON:\n%s", got)
+	}
+}
@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+)
+
+// defaultMaxRecords caps all_pages when max_records is not given. The *arr
+// services page their queue and history in tens, so without a cap a large
+// history turns into hundreds of requests.
+const defaultMaxRecords = 1000
+
+// maxRecordsLimit is the most a caller can raise max_records to. Every record
+// is held in memory and marshalled into one response.
+const maxRecordsLimit = 10000
+
+// pageConcurrency is how many workers fetch pages, so paging a long history
+// does not hammer a small server.
+const pageConcurrency = 4
+
+// pagedEnvelope is a recognised paged response: the items on this page, the
+// page it is, and how to ask for another.
+type pagedEnvelope struct {
+	obj      map[string]any
+	itemsKey string
+	page     int
+	pageSize int
+	total    int
+	// pageQuery sets the query parameters that select page n.
+	pageQuery func(q url.Values, n int)
+}
+
+// detectPaging recognises the two paged shapes the services use: the *arr
+// {page, pageSize, totalRecords, records} envelope, and Seerr's
+// {pageInfo: {page, pages, pageSize, results}, results} with take and skip.
+func detectPaging(resp any) *pagedEnvelope {
+	obj, ok := resp.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if _, ok := obj["records"].([]any); ok {
+		total, okTotal := obj["totalRecords"].(float64)
+		size, okSize := obj["pageSize"].(float64)
+		if okTotal && okSize && size > 0 {
+			page, _ := obj["page"].(float64)
+			return &pagedEnvelope{
+				obj: obj, itemsKey: "records",
+				page: max(int(page), 1), pageSize: int(size), total: int(total),
+				pageQuery: func(q url.Values, n int) {
+					q.Set("page", strconv.Itoa(n))
+					q.Set("pageSize", strconv.Itoa(int(size)))
+				},
+			}
+		}
+	}
+	if _, ok := obj["results"].([]any); ok {
+		info, _ := obj["pageInfo"].(map[string]any)
+		total, okTotal := info["results"].(float64)
+		size, okSize := info["pageSize"].(float64)
+		if okTotal && okSize && size > 0 {
+			page, _ := info["page"].(float64)
+			return &pagedEnvelope{
+				obj: obj, itemsKey: "results",
+				page: max(int(page), 1), pageSize: int(size), total: int(total),
+				pageQuery: func(q url.Values, n int) {
+					q.Set("take", strconv.Itoa(int(size)))
+					q.Set("skip", strconv.Itoa((n-1)*int(size)))
+				},
+			}
+		}
+	}
+	return nil
+}
+
+// fetchAllPages follows a paged GET from its first page until maxRecords
+// items are in hand or the pages run out, and returns the first page's
+// envelope holding every item, in page order. The remaining pages are
+// fetched by pageConcurrency workers, and the first page that fails stops
+// the rest. The note says what was left behind, if anything.
+func fetchAllPages(ctx context.Context, svc *arrservice.Service, path string, query url.Values, first []byte, maxRecords int) ([]byte, string, error) {
+	var decoded any
+	if err := json.Unmarshal(first, &decoded); err != nil {
+		return first, "all_pages: the response is not JSON, so it was returned as is.", nil
+	}
+	env := detectPaging(decoded)
+	if env == nil {
+		return first, "all_pages: the response is not a paged envelope, so it was returned as is.", nil
+	}
+
+	// Pages before the first one asked for are not fetched, so a call that
+	// starts at page 3 collects from there.
+	firstItems, _ := env.obj[env.itemsKey].([]any)
+	remaining := max(env.total-(env.page-1)*env.pageSize, len(firstItems))
+	maxRecords = min(maxRecords, maxRecordsLimit)
+	want := min(remaining, maxRecords)
+	lastPage := max(env.page+int(math.Ceil(float64(want)/float64(env.pageSize)))-1, env.page)
+
+	pages := make([][]any, lastPage-env.page+1)
+	pages[0] = firstItems
+	if err := fetchPages(ctx, svc, path, query, env, pages[1:]); err != nil {
+		return nil, "", err
+	}
+
+	items := []any{}
+	for _, p := range pages {
+		items = append(items, p...)
+	}
+	if len(items) > want {
+		items = items[:want]
+	}
+	env.obj[env.itemsKey] = items
+	merged, err := json.Marshal(env.obj)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var note string
+	switch {
+	case len(items) >= remaining:
+	case maxRecords == maxRecordsLimit:
+		note = fmt.Sprintf("all_pages: returned %d of %d records, the most it collects. Narrow the query for the rest.", len(items), remaining)
+	default:
+		note = fmt.Sprintf("all_pages: returned %d of %d records. Raise max_records (up to %d), or narrow the query, for the rest.", len(items), remaining, maxRecordsLimit)
+	}
+	return merged, note, nil
+}
+
+// fetchPages fills pages[i] with page env.page+1+i. Once one page fails, the
+// pages still in flight are cancelled and no more are started, and that
+// page's error is returned.
+func fetchPages(ctx context.Context, svc *arrservice.Service, path string, query url.Values, env *pagedEnvelope, pages [][]any) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	next := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for range min(pageConcurrency, len(pages)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				items, err := fetchPage(ctx, svc, path, query, env, env.page+1+i)
+				if err != nil {
+					once.Do(func() { firstErr = err; cancel() })
+					continue
+				}
+				pages[i] = items
+			}
+		}()
+	}
+feed:
+	for i := range pages {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	// The caller may have given up before every page was handed out.
+	return ctx.Err()
+}
+
+// fetchPage requests page n with the caller's other query parameters.
+func fetchPage(ctx context.Context, svc *arrservice.Service, path string, query url.Values, env *pagedEnvelope, n int) ([]any, error) {
+	q := url.Values{}
+	for k, vs := range query {
+		q[k] = vs
+	}
+	env.pageQuery(q, n)
+	body, status, err := svc.DoRequest(ctx, "GET", path, q, nil)
+	if err != nil {
+		return nil, fmt.Errorf("page %d: request failed: %v", n, err)
+	}
+	if status < 200 || status > 299 {
+		// The body is left out: unlike the first page's, it has not been
+		// through secret masking.
+		return nil, fmt.Errorf("page %d: HTTP %d", n, status)
+	}
+	var page map[string]any
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("page %d: invalid JSON: %v", n, err)
+	}
+	items, _ := page[env.itemsKey].([]any)
+	return items, nil
+}
@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// pagedServer serves total numbered records pageSize at a time, in the *arr
+// envelope or, with seerr set, in Seerr's pageInfo/results shape.
+func pagedServer(total, pageSize int, seerr bool, requests *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		q := r.URL.Query()
+		page := 1
+		if seerr {
+			skip, _ := strconv.Atoi(q.Get("skip"))
+			page = skip/pageSize + 1
+		} else if p, err := strconv.Atoi(q.Get("page")); err == nil {
+			page = p
+		}
+		var items []map[string]any
+		for id := (page-1)*pageSize + 1; id <= min(page*pageSize, total); id++ {
+			items = append(items, map[string]any{"id": id, "status": []string{"queued", "downloading"}[id%2]})
+		}
+		resp := map[string]any{"page": page, "pageSize": pageSize, "totalRecords": total, "records": items}
+		if seerr {
+			pages := (total + pageSize - 1) / pageSize
+			resp = map[string]any{"pageInfo": map[string]any{"page": page, "pages": pages, "pageSize": pageSize, "results": total}, "results": items}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func TestCallAPIAllPages(t *testing.T) {
+	ids := func(t *testing.T, text, key string) string {
+		t.Helper()
+		var env map[string]any
+		if err := json.Unmarshal([]byte(text), &env); err != nil {
+			t.Fatalf("%v: %s", err, text)
+		}
+		var got []string
+		for _, item := range env[key].([]any) {
+			got = append(got, fmt.Sprint(item.(map[string]any)["id"]))
+		}
+		return strings.Join(got, ",")
+	}
+
+	for _, tt := range []struct {
+		name     string
+		seerr    bool
+		args     map[string]any
+		key      string
+		want     string
+		requests int32
+		note     string
+	}{
+		{"arr envelope", false, map[string]any{}, "records", "1,2,3,4,5,6,7", 3, ""},
+		{"seerr envelope", true, map[string]any{}, "results", "1,2,3,4,5,6,7", 3, ""},
+		{"capped", false, map[string]any{"max_records": "4"}, "records", "1,2,3,4", 2, "returned 4 of 7"},
+		{"filter sees every page", false, map[string]any{"filter": "status:eq:queued", "fields": "records.id"}, "records", "2,4,6", 3, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			tt.args["path"] = "/queue"
+			tt.args["all_pages"] = true
+			res := callAPI(t, pagedServer(7, 3, tt.seerr, &requests), tt.args)
+			if res.IsError {
+				t.Fatal(resultText(t, res))
+			}
+			if got := ids(t, res.Content[0].(mcp.TextContent).Text, tt.key); got != tt.want {
+				t.Errorf("ids = %s, want %s", got, tt.want)
+			}
+			if requests.Load() != tt.requests {
+				t.Errorf("%d requests, want %d", requests.Load(), tt.requests)
+			}
+			var note string
+			if len(res.Content) > 1 {
+				note = res.Content[1].(mcp.TextContent).Text
+			}
+			if tt.note == "" && note != "" || !strings.Contains(note, tt.note) {
+				t.Errorf("note = %q, want %q", note, tt.note)
+			}
+		})
+	}
+
+	res := callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": 1}]`))
+	}, map[string]any{"path": "/series", "all_pages": true})
+	if res.IsError || len(res.Content) != 2 || !strings.Contains(resultText(t, res), `"id":1`) {
+		t.Errorf("unpaged response = %v", res.Content)
+	}
+
+	res = callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("all_pages on a POST reached the service")
+	}, map[string]any{"path": "/command", "method": "POST", "all_pages": true})
+	if !res.IsError {
+		t.Errorf("all_pages on a POST = %s", resultText(t, res))
+	}
+}
+
+// max_records cannot be raised past maxRecordsLimit, and a page that fails
+// stops the pages after it from being requested.
+func TestFetchAllPagesBounds(t *testing.T) {
+	var requests atomic.Int32
+	res := callAPI(t, pagedServer(30000, 1000, false, &requests), map[string]any{
+		"path": "/queue", "all_pages": true, "max_records": "1000000", "fields": "totalRecords",
+	})
+	if res.IsError {
+		t.Fatal(resultText(t, res))
+	}
+	if got := requests.Load(); got != maxRecordsLimit/1000 {
+		t.Errorf("%d requests, want %d", got, maxRecordsLimit/1000)
+	}
+	if _, notes := splitResult(res); !strings.Contains(strings.Join(notes, "\n"), "returned 10000 of 30000 records, the most it collects") {
+		t.Errorf("notes = %q", notes)
+	}
+
+	requests.Store(0)
+	serve := pagedServer(500, 1, false, &requests)
+	res = callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "3" {
+			requests.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		serve(w, r)
+	}, map[string]any{"path": "/queue", "all_pages": true})
+	if !res.IsError || !strings.Contains(resultText(t, res), "page 3: HTTP 500") {
+		t.Errorf("failed page = %s", resultText(t, res))
+	}
+	if got := requests.Load(); got > 3+2*pageConcurrency {
+		t.Errorf("%d requests after page 3 failed; the rest were not cancelled", got)
+	}
+}
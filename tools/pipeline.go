@@ -0,0 +1,466 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jmespath/go-jmespath"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const maxPipelineSteps = 20
+
+// pipelineTool reports whether a pipeline step may call the named tool:
+// call_api and call_api_batch, and the download client tools.
+func pipelineTool(name string) bool {
+	switch name {
+	case "call_api", "call_api_batch":
+		return true
+	}
+	for _, prefix := range []string{"transmission_", "qbit_", "sabnzbd_"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// registerPipelineTool adds run_pipeline, whose steps call the handlers in
+// tools. They are passed in rather than looked up on s because on a reload s
+// is a scratch server: the live server gets the same handlers, and the
+// pipeline must call those.
+func registerPipelineTool(s *server.MCPServer, tools map[string]*server.ServerTool, paused *pausedPipelines) {
+	s.AddTool(
+		mcp.NewTool("run_pipeline",
+			mcp.WithDescription(fmt.Sprintf("Run up to %d tool calls in order, where later steps use earlier results, and return only the final output and a trace of the steps. For \"look up X, take its id, then do Y\" tasks, such as /series/lookup then POST /series, or GET /movie?tmdbId= then POST /command MoviesSearch. Stops at the first step that fails. A step that needs confirmation stops the pipeline with its preview and a resume token, which carries on from that step without running the earlier ones again.", maxPipelineSteps)),
+			mcp.WithString("steps", mcp.Required(), mcp.Description("JSON array of steps: {\"name\": \"lookup\", \"tool\": \"call_api\", \"args\": {...}}. tool defaults to call_api, and may also be call_api_batch or a transmission_, qbit_ or sabnzbd_ tool. A string in args that contains ${expr} is filled from earlier results: expr is JMESPath over the results by step name, e.g. \"${lookup[0].tvdbId}\". A value that is only ${expr} keeps the result's type, so it can be a number, list or object")),
+			mcp.WithString("result", mcp.Description("JMESPath over the results by step name, giving the output (e.g. \"{id: add.id, title: add.title}\"). Defaults to the last step's result")),
+			mcp.WithBoolean("stop_on_error", mcp.Description("Stop at the first failed step (default true). When false, later steps still run, and any that refer to a failed step fail too")),
+			mcp.WithString("resume", mcp.Description("Resume token from a pipeline that stopped at a confirmation preview. Send the same steps with confirm set in the previewed step's args; the steps before it are not run again, and their earlier results are used")),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return handleRunPipeline(ctx, req, tools, paused), nil
+		},
+	)
+}
+
+// pipelineStep is one tool call in a pipeline.
+type pipelineStep struct {
+	Name string         `json:"name"`
+	Tool string         `json:"tool"`
+	Args map[string]any `json:"args"`
+}
+
+// stepTrace is what the pipeline reports about a step. Status is ok, error,
+// skipped, confirm when the step returned a preview instead of running, or
+// resumed when it ran before the pipeline was paused.
+type stepTrace struct {
+	Name   string `json:"name"`
+	Tool   string `json:"tool"`
+	Call   string `json:"call,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// pausedPipelines holds pipelines stopped at a preview, so that once the user
+// agrees they carry on from that step instead of running the earlier ones,
+// which may have changed something, again. Each resume token works once.
+type pausedPipelines struct {
+	mu   sync.Mutex
+	runs map[string]pausedPipeline
+}
+
+type pausedPipeline struct {
+	client  string
+	done    string         // the steps before the paused one, as JSON
+	from    int            // index of the paused step
+	results map[string]any // the earlier steps' results by name
+	expires time.Time
+}
+
+func newPausedPipelines() *pausedPipelines {
+	return &pausedPipelines{runs: make(map[string]pausedPipeline)}
+}
+
+// pause keeps the results of steps[:from] for client and returns a resume
+// token. It lasts as long as the preview's confirmation token.
+func (p *pausedPipelines) pause(client string, steps []pipelineStep, from int, results map[string]any) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	done, _ := json.Marshal(steps[:from])
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for t, run := range p.runs {
+		if now.After(run.expires) {
+			delete(p.runs, t)
+		}
+	}
+	p.runs[token] = pausedPipeline{client: client, done: string(done), from: from, results: maps.Clone(results), expires: now.Add(confirmTTL)}
+	return token
+}
+
+// resume consumes token and returns the paused step's index and the results
+// before it. The steps before it must be the ones that ran; a token that
+// does not match is left for a corrected retry.
+func (p *pausedPipelines) resume(token, client string, steps []pipelineStep) (int, map[string]any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	run, ok := p.runs[token]
+	if !ok || run.client != client || time.Now().After(run.expires) {
+		return 0, nil, fmt.Errorf("resume token %q is unknown or has expired; run the pipeline without it", token)
+	}
+	if run.from >= len(steps) {
+		return 0, nil, fmt.Errorf("resume token %q is for step %d, but the pipeline has %d", token, run.from+1, len(steps))
+	}
+	if done, _ := json.Marshal(steps[:run.from]); string(done) != run.done {
+		return 0, nil, fmt.Errorf("steps 1 to %d differ from the ones resume token %q was issued for; send them unchanged", run.from, token)
+	}
+	delete(p.runs, token)
+	return run.from, run.results, nil
+}
+
+func handleRunPipeline(ctx context.Context, req mcp.CallToolRequest, tools map[string]*server.ServerTool, paused *pausedPipelines) *mcp.CallToolResult {
+	steps, err := pipelineSteps(req)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	var result *jmespath.JMESPath
+	if r := mcp.ParseString(req, "result", ""); r != "" {
+		if result, err = parseExpr(r); err != nil {
+			return mcp.NewToolResultError("result: " + err.Error())
+		}
+	}
+	stopOnError := mcp.ParseBoolean(req, "stop_on_error", true)
+
+	results := make(map[string]any, len(steps))
+	from := 0
+	if token := mcp.ParseString(req, "resume", ""); token != "" {
+		if from, results, err = paused.resume(token, clientName(ctx), steps); err != nil {
+			return mcp.NewToolResultError(err.Error())
+		}
+	}
+
+	trace := make([]stepTrace, len(steps))
+	var last any
+	var resume string
+	failed, stopped := false, false
+	for i, step := range steps {
+		if i < from {
+			trace[i] = stepTrace{Name: step.Name, Tool: step.Tool, Status: "resumed"}
+			last = results[step.Name]
+			continue
+		}
+		trace[i] = stepTrace{Name: step.Name, Tool: step.Tool, Status: "skipped"}
+		if stopped {
+			continue
+		}
+		out, t := runStep(ctx, tools, step, results)
+		trace[i] = t
+		switch t.Status {
+		case "ok":
+			results[step.Name], last = out, out
+			continue
+		case "confirm":
+			// The preview is what the user has to see; nothing after it may
+			// run until they agree.
+			last, stopped = out, true
+			resume = paused.pause(clientName(ctx), steps, i, results)
+			trace[i].Note = strings.TrimSpace(t.Note + "\nThis step and the ones after it did not run. If the user agrees, send the same steps again with confirm set in this step's args and resume set to " + resume + ", so the steps before it are not run again.")
+			continue
+		}
+		failed = true
+		stopped = stopOnError
+	}
+
+	output := last
+	switch {
+	case failed && stopped:
+		output = nil // the trace says which step failed
+	case result != nil && !stopped:
+		if output, err = result.Search(results); err != nil {
+			failed = true
+			output = fmt.Sprintf("result failed: %v", err)
+		}
+	}
+	data, _ := json.Marshal(struct {
+		Output any         `json:"output"`
+		Steps  []stepTrace `json:"steps"`
+		Resume string      `json:"resume,omitempty"`
+	}{output, trace, resume})
+	if failed {
+		return mcp.NewToolResultError(string(data))
+	}
+	return mcp.NewToolResultText(string(data))
+}
+
+// pipelineSteps reads and checks the steps argument, so a misnamed tool or a
+// bad expression in the last step is caught before the first one runs.
+func pipelineSteps(req mcp.CallToolRequest) ([]pipelineStep, error) {
+	raw := req.GetArguments()["steps"]
+	data, ok := raw.(string)
+	if !ok {
+		b, _ := json.Marshal(raw)
+		data = string(b)
+	}
+	var steps []pipelineStep
+	if err := json.Unmarshal([]byte(data), &steps); err != nil {
+		return nil, fmt.Errorf("steps must be a JSON array of {name, tool, args} objects: %v", err)
+	}
+	if len(steps) == 0 || len(steps) > maxPipelineSteps {
+		return nil, fmt.Errorf("a pipeline takes 1 to %d steps, got %d", maxPipelineSteps, len(steps))
+	}
+	seen := make(map[string]bool)
+	for i := range steps {
+		st := &steps[i]
+		if st.Name == "" {
+			st.Name = fmt.Sprintf("step%d", i+1)
+		}
+		if st.Tool == "" {
+			st.Tool = "call_api"
+		}
+		if seen[st.Name] {
+			return nil, fmt.Errorf("step %d: name %q is used twice", i+1, st.Name)
+		}
+		seen[st.Name] = true
+		if !pipelineTool(st.Tool) {
+			return nil, fmt.Errorf("step %s: tool %q cannot run in a pipeline (use call_api, call_api_batch, or a transmission_, qbit_ or sabnzbd_ tool)", st.Name, st.Tool)
+		}
+		if err := walkStrings(st.Args, func(s string) error {
+			_, err := references(s)
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("step %s: %v", st.Name, err)
+		}
+	}
+	return steps, nil
+}
+
+// runStep fills in a step's arguments from earlier results and calls its
+// tool, returning the decoded result.
+func runStep(ctx context.Context, tools map[string]*server.ServerTool, step pipelineStep, results map[string]any) (any, stepTrace) {
+	t := stepTrace{Name: step.Name, Tool: step.Tool, Status: "error"}
+	args, err := substitute(step.Args, results)
+	if err != nil {
+		t.Error = err.Error()
+		return nil, t
+	}
+	argMap, _ := args.(map[string]any)
+	if argMap == nil {
+		argMap = map[string]any{}
+	}
+	if svc, _ := argMap["service"].(string); svc != "" {
+		method, _ := argMap["method"].(string)
+		path, _ := argMap["path"].(string)
+		t.Call = strings.TrimSpace(fmt.Sprintf("%s %s %s", svc, strings.ToUpper(cmp.Or(method, "GET")), path))
+	}
+
+	// The client's scoping only sees run_pipeline itself, so each step is
+	// checked here.
+	client := auth.FromContext(ctx)
+	svc, _ := argMap["service"].(string)
+	switch tool := tools[step.Tool]; {
+	case !client.AllowsTool(step.Tool):
+		t.Error = fmt.Sprintf("tool %q is not allowed for client %q", step.Tool, client.Name)
+	case svc != "" && !client.AllowsService(svc):
+		t.Error = fmt.Sprintf("service %q is not allowed for client %q", svc, client.Name)
+	case tool == nil:
+		t.Error = fmt.Sprintf("tool %q is not available; its service may not be configured", step.Tool)
+	default:
+		res, err := tool.Handler(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Name: step.Tool, Arguments: argMap}})
+		if err != nil {
+			t.Error = err.Error()
+			return nil, t
+		}
+		text, notes := splitResult(res)
+		t.Note = strings.Join(notes, "\n")
+		if res.IsError {
+			t.Error = text
+			return nil, t
+		}
+		var out any = text
+		var decoded any
+		if json.Unmarshal([]byte(text), &decoded) == nil {
+			out = decoded
+		}
+		t.Status = "ok"
+		if isPreview(text) {
+			t.Status = "confirm"
+		}
+		return out, t
+	}
+	return nil, t
+}
+
+// references returns the ${expr} expressions in s, compiled.
+func references(s string) ([]*jmespath.JMESPath, error) {
+	var exprs []*jmespath.JMESPath
+	for _, span := range referenceSpans(s) {
+		if span.end < 0 {
+			return nil, fmt.Errorf("unclosed ${ in %q", s)
+		}
+		jp, err := parseExpr(s[span.start+2 : span.end])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s[span.start:span.end+1], err)
+		}
+		exprs = append(exprs, jp)
+	}
+	return exprs, nil
+}
+
+type span struct{ start, end int } // end is the closing brace, or -1
+
+// referenceSpans finds each ${...} in s. JMESPath has braces and quoted
+// strings of its own, so the closing brace is found by depth, outside quotes.
+func referenceSpans(s string) []span {
+	var spans []span
+	for i := 0; i < len(s); i++ {
+		if !strings.HasPrefix(s[i:], "${") {
+			continue
+		}
+		sp := span{start: i, end: -1}
+		depth, quote := 0, byte(0)
+		for j := i + 2; j < len(s); j++ {
+			c := s[j]
+			switch {
+			case quote != 0:
+				if c == '\\' {
+					j++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"' || c == '`':
+				quote = c
+			case c == '{':
+				depth++
+			case c == '}' && depth > 0:
+				depth--
+			case c == '}':
+				sp.end = j
+			}
+			if sp.end >= 0 {
+				break
+			}
+		}
+		spans = append(spans, sp)
+		if sp.end < 0 {
+			break
+		}
+		i = sp.end
+	}
+	return spans
+}
+
+// substitute returns v with every ${expr} in its strings filled from results.
+// A string that is a single reference becomes the value itself, so
+// "${lookup[0].tvdbId}" stays a number; a reference within other text is
+// written as text, with lists and objects as compact JSON.
+func substitute(v any, results map[string]any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			sub, err := substitute(val, results)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = sub
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			sub, err := substitute(val, results)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = sub
+		}
+		return out, nil
+	case string:
+		spans := referenceSpans(v)
+		if len(spans) == 0 {
+			return v, nil
+		}
+		values := make([]any, len(spans))
+		for i, sp := range spans {
+			ref := v[sp.start : sp.end+1]
+			jp, err := parseExpr(v[sp.start+2 : sp.end])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", ref, err)
+			}
+			val, err := jp.Search(results)
+			if err != nil {
+				return nil, fmt.Errorf("%s failed: %v", ref, err)
+			}
+			if val == nil {
+				return nil, fmt.Errorf("%s has no value; results so far: %s", ref, strings.Join(slices.Sorted(maps.Keys(results)), ", "))
+			}
+			values[i] = val
+		}
+		if len(spans) == 1 && spans[0].start == 0 && spans[0].end == len(v)-1 {
+			return values[0], nil
+		}
+		var b strings.Builder
+		prev := 0
+		for i, sp := range spans {
+			b.WriteString(v[prev:sp.start])
+			b.WriteString(referenceText(values[i]))
+			prev = sp.end + 1
+		}
+		b.WriteString(v[prev:])
+		return b.String(), nil
+	}
+	return v, nil
+}
+
+func referenceText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		// Ids come back from JSON as float64, and must not be written as
+		// 1.234567e+06.
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]any, []any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// walkStrings calls fn on every string in v.
+func walkStrings(v any, fn func(string) error) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, val := range v {
+			if err := walkStrings(val, fn); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, val := range v {
+			if err := walkStrings(val, fn); err != nil {
+				return err
+			}
+		}
+	case string:
+		return fn(v)
+	}
+	return nil
+}
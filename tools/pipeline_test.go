@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// pipelineServer registers call_api and run_pipeline against a stub Sonarr.
+func pipelineServer(t *testing.T, handler http.HandlerFunc) *server.MCPServer {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	s := server.NewMCPServer("test", "0.0.0")
+	registerAPICallTool(s, registry, nil, 12800, testGuard(false))
+	registerPipelineTool(s, s.ListTools(), newPausedPipelines())
+	return s
+}
+
+// The lookup-then-add pattern: later steps take ids from earlier results,
+// and only the final output comes back.
+func TestRunPipeline(t *testing.T) {
+	bodies := map[string]string{}
+	s := pipelineServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies[r.Method+" "+r.URL.Path] = string(body)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v3/series/lookup":
+			w.Write([]byte(`[{"title": "Andor", "tvdbId": 393189, "year": 2022}]`))
+		case "POST /api/v3/series":
+			w.Write([]byte(`{"id": 7, "title": "Andor", "monitored": true}`))
+		case "POST /api/v3/command":
+			w.Write([]byte(`{"id": 501, "name": "SeriesSearch", "status": "queued"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "NotFound"}`))
+		}
+	})
+
+	res := callTool(t, s, "run_pipeline", map[string]any{
+		"steps": `[
+		  {"name": "lookup", "args": {"service": "sonarr", "path": "/series/lookup", "query": {"term": "andor"}}},
+		  {"name": "add", "args": {"service": "sonarr", "method": "POST", "path": "/series",
+		    "body": {"title": "${lookup[0].title}", "tvdbId": "${lookup[0].tvdbId}", "rootFolderPath": "/tv/${lookup[0].title} (${lookup[0].year})"}}},
+		  {"name": "search", "args": {"service": "sonarr", "method": "POST", "path": "/command", "body": {"name": "SeriesSearch", "seriesId": "${add.id}"}}}
+		]`,
+		"result": "{seriesId: add.id, command: search.status}",
+	})
+	if res.IsError {
+		t.Fatal(resultText(t, res))
+	}
+	var out struct {
+		Output map[string]any
+		Steps  []stepTrace
+	}
+	if err := json.Unmarshal([]byte(resultText(t, res)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Output["seriesId"] != 7.0 || out.Output["command"] != "queued" {
+		t.Errorf("output = %v", out.Output)
+	}
+	if len(out.Steps) != 3 || out.Steps[1].Status != "ok" || out.Steps[1].Call != "sonarr POST /series" {
+		t.Errorf("steps = %+v", out.Steps)
+	}
+	if got, want := bodies["POST /api/v3/series"], `{"rootFolderPath":"/tv/Andor (2022)","title":"Andor","tvdbId":393189}`; got != want {
+		t.Errorf("add body = %s, want %s", got, want)
+	}
+	if got := bodies["POST /api/v3/command"]; !strings.Contains(got, `"seriesId":7`) {
+		t.Errorf("search body = %s", got)
+	}
+
+	// A failed step stops the pipeline before anything after it is sent.
+	clear(bodies)
+	res = callTool(t, s, "run_pipeline", map[string]any{"steps": `[
+	  {"name": "lookup", "args": {"service": "sonarr", "path": "/series/missing"}},
+	  {"name": "add", "args": {"service": "sonarr", "method": "POST", "path": "/series", "body": {"tvdbId": "${lookup.tvdbId}"}}}
+	]`})
+	text := resultText(t, res)
+	if !res.IsError || !strings.Contains(text, `"output":null`) || !strings.Contains(text, `"status":"skipped"`) || !strings.Contains(text, "HTTP 404") {
+		t.Errorf("failed pipeline = %s", text)
+	}
+	if _, sent := bodies["POST /api/v3/series"]; sent {
+		t.Error("a step after the failure was sent")
+	}
+}
+
+func TestPipelineStepsErrors(t *testing.T) {
+	for _, tt := range []struct{ steps, want string }{
+		{`[]`, "1 to 20 steps"},
+		{`{"name": "a"}`, "JSON array"},
+		{`[{"tool": "run_pipeline"}]`, "cannot run in a pipeline"},
+		{`[{"tool": "audit_log"}]`, "cannot run in a pipeline"},
+		{`[{"name": "a"}, {"name": "a"}]`, "used twice"},
+		{`[{"args": {"path": "/series/${lookup[}"}}]`, "invalid expr"},
+		{`[{"args": {"path": "/series/${lookup.id"}}]`, "unclosed ${"},
+	} {
+		s := server.NewMCPServer("test", "0.0.0")
+		registerPipelineTool(s, nil, newPausedPipelines())
+		res := callTool(t, s, "run_pipeline", map[string]any{"steps": tt.steps})
+		if got := resultText(t, res); !res.IsError || !strings.Contains(got, tt.want) {
+			t.Errorf("steps %s: got %q, want an error containing %q", tt.steps, got, tt.want)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	results := map[string]any{
+		"lookup": []any{map[string]any{"id": 1234567.0, "title": "Andor", "tags": []any{1.0, 2.0}}},
+	}
+	for _, tt := range []struct {
+		in   any
+		want string
+	}{
+		{"${lookup[0].id}", `1234567`},
+		{"/series/${lookup[0].id}", `"/series/1234567"`},
+		{"${lookup[0].tags}", `[1,2]`},
+		{"tags=${lookup[0].tags}", `"tags=[1,2]"`},
+		{"${lookup[0].{t: title}}", `{"t":"Andor"}`},
+		{map[string]any{"ids": []any{"${lookup[0].id}"}}, `{"ids":[1234567]}`},
+		{"no references", `"no references"`},
+	} {
+		got, err := substitute(tt.in, results)
+		if err != nil {
+			t.Errorf("%v: %v", tt.in, err)
+			continue
+		}
+		if data, _ := json.Marshal(got); string(data) != tt.want {
+			t.Errorf("%v = %s, want %s", tt.in, data, tt.want)
+		}
+	}
+	if _, err := substitute("${add.id}", results); err == nil || !strings.Contains(err.Error(), "results so far: lookup") {
+		t.Errorf("missing value err = %v", err)
+	}
+}
+
+// A step that needs confirmation pauses the pipeline. Resuming it runs that
+// step with its confirm token, using the earlier results rather than
+// running the earlier steps again.
+func TestRunPipelineResume(t *testing.T) {
+	lookups, deletes := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "DELETE":
+			deletes++
+		case "GET":
+			if r.URL.Path == "/api/v3/series/lookup" {
+				lookups++
+				w.Write([]byte(`[{"id": 7, "title": "Firefly"}]`))
+				return
+			}
+		}
+		w.Write([]byte(`{"id": 7, "title": "Firefly"}`))
+	}))
+	defer srv.Close()
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	s := server.NewMCPServer("test", "0.0.0")
+	registerAPICallTool(s, registry, nil, 12800, newGuard(policy.New(config.PolicyConfig{}), true, true, nil, NewState()))
+	registerPipelineTool(s, s.ListTools(), newPausedPipelines())
+
+	steps := func(deleteArgs string) string {
+		return `[{"name": "lookup", "args": {"service": "sonarr", "path": "/series/lookup", "query": {"term": "firefly"}}},
+		  {"name": "remove", "args": {"service": "sonarr", "method": "DELETE", "path": "/series/${lookup[0].id}"` + deleteArgs + `}}]`
+	}
+	var paused struct {
+		Output confirmPreview
+		Steps  []stepTrace
+		Resume string
+	}
+	res := callTool(t, s, "run_pipeline", map[string]any{"steps": steps("")})
+	if err := json.Unmarshal([]byte(resultText(t, res)), &paused); err != nil || paused.Resume == "" || paused.Output.Confirm == "" {
+		t.Fatalf("expected a preview and a resume token (err=%v): %s", err, resultText(t, res))
+	}
+	if paused.Steps[1].Status != "confirm" || !strings.Contains(paused.Steps[1].Note, paused.Resume) {
+		t.Errorf("paused step = %+v", paused.Steps[1])
+	}
+
+	confirmed := steps(`, "confirm": "` + paused.Output.Confirm + `"`)
+	res = callTool(t, s, "run_pipeline", map[string]any{"steps": `[{"name": "lookup", "args": {"service": "sonarr", "path": "/series/lookup"}}]`, "resume": paused.Resume})
+	if got := resultText(t, res); !res.IsError || !strings.Contains(got, "is for step 2") {
+		t.Errorf("resume with fewer steps = %s", got)
+	}
+	res = callTool(t, s, "run_pipeline", map[string]any{"steps": strings.Replace(confirmed, "firefly", "serenity", 1), "resume": paused.Resume})
+	if got := resultText(t, res); !res.IsError || !strings.Contains(got, "differ") {
+		t.Errorf("resume with changed steps = %s", got)
+	}
+
+	res = callTool(t, s, "run_pipeline", map[string]any{"steps": confirmed, "resume": paused.Resume})
+	text := resultText(t, res)
+	if res.IsError || !strings.Contains(text, `"status":"resumed"`) {
+		t.Fatalf("resumed pipeline = %s", text)
+	}
+	if lookups != 1 || deletes != 1 {
+		t.Errorf("%d lookups and %d deletes, want 1 of each", lookups, deletes)
+	}
+
+	res = callTool(t, s, "run_pipeline", map[string]any{"steps": confirmed, "resume": paused.Resume})
+	if got := resultText(t, res); !res.IsError || !strings.Contains(got, "unknown or has expired") {
+		t.Errorf("second resume = %s", got)
+	}
+}
@@ -4,26 +4,31 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/jakenesler/navigatorr/format"
+	"github.com/jakenesler/navigatorr/policy"
 	"github.com/jakenesler/navigatorr/qbit"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-func registerQbitTools(s *server.MCPServer, client *qbit.Client, allowDestructive bool) {
+func registerQbitTools(s *server.MCPServer, client *qbit.Client, g *guard) {
 	// qbit_list_torrents
 	s.AddTool(
 		mcp.NewTool("qbit_list_torrents",
 			mcp.WithDescription("List all torrents in qBittorrent with status, progress, and speed info"),
+			withOutput(format.JSON),
+			withColumns(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			torrents, err := client.ListTorrents(ctx)
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("failed to list torrents: %v", err)), nil
 			}
-			data, _ := json.MarshalIndent(torrents, "", "  ")
-			return mcp.NewToolResultText(string(data)), nil
+			return outputResult(req, torrents), nil
 		},
 	)
 
@@ -33,12 +38,29 @@ func registerQbitTools(s *server.MCPServer, client *qbit.Client, allowDestructiv
 			mcp.WithDescription("Add a torrent to qBittorrent by magnet link or URL"),
 			mcp.WithString("url", mcp.Required(), mcp.Description("Magnet link or torrent URL")),
 			mcp.WithString("save_path", mcp.Description("Download save path (optional)")),
+			withConfirm(),
+			withDryRun(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			url := mcp.ParseString(req, "url", "")
 			savePath := mcp.ParseString(req, "save_path", "")
 
-			if err := client.AddTorrent(ctx, url, savePath); err != nil {
+			body := map[string]any{"urls": url}
+			if savePath != "" {
+				body["savepath"] = savePath
+			}
+			pr := qbitRequest("/torrents/add", body)
+			if g.dryRunning(req) {
+				return qbitDryRun(ctx, client, g, pr, "Adding"), nil
+			}
+			if refusal := g.check(ctx, req, pr, "Adding", nil); refusal != nil {
+				return refusal, nil
+			}
+
+			start := time.Now()
+			err := client.AddTorrent(ctx, url, savePath)
+			g.record(ctx, req, pr, start, 0, err, nil)
+			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("failed to add torrent: %v", err)), nil
 			}
 			return mcp.NewToolResultText("Torrent added successfully"), nil
@@ -51,6 +73,8 @@ func registerQbitTools(s *server.MCPServer, client *qbit.Client, allowDestructiv
 			mcp.WithDescription("Manage qBittorrent torrents: pause, resume, or delete by hash"),
 			mcp.WithString("action", mcp.Required(), mcp.Description("Action: pause, resume, delete, delete_files")),
 			mcp.WithString("hashes", mcp.Required(), mcp.Description("Comma-separated torrent hashes (or \"all\" for all torrents)")),
+			withConfirm(),
+			withDryRun(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			action := mcp.ParseString(req, "action", "")
@@ -61,12 +85,28 @@ func registerQbitTools(s *server.MCPServer, client *qbit.Client, allowDestructiv
 				return mcp.NewToolResultError("hashes is required"), nil
 			}
 
-			// qBittorrent deletes go out as POSTs to /torrents/delete, so the
-			// DELETE-verb guard in call_api never sees them.
-			if (action == "delete" || action == "delete_files") && !allowDestructive {
-				return mcp.NewToolResultError("Deleting is disabled. Set allow_destructive: true in config.yaml to enable."), nil
+			// qBittorrent deletes go out as POSTs to /torrents/delete, so a
+			// rule on the DELETE verb never sees them; describe the call as
+			// qBittorrent's API spells it.
+			endpoint, what := "/torrents/"+action, "Running "+action
+			body := map[string]any{"hashes": strings.Join(hashes, "|")}
+			switch action {
+			case "pause", "resume":
+			case "delete", "delete_files":
+				endpoint, what = "/torrents/delete", "Deleting"
+				body["deleteFiles"] = action == "delete_files"
+			default:
+				return mcp.NewToolResultError(fmt.Sprintf("unknown action %q (use: pause, resume, delete, delete_files)", action)), nil
+			}
+			pr := qbitRequest(endpoint, body)
+			if g.dryRunning(req) {
+				return qbitDryRun(ctx, client, g, pr, what), nil
+			}
+			if refusal := g.check(ctx, req, pr, what, qbitPreview(client, hashes)); refusal != nil {
+				return refusal, nil
 			}
 
+			start := time.Now()
 			var err error
 			switch action {
 			case "pause":
@@ -77,9 +117,8 @@ func registerQbitTools(s *server.MCPServer, client *qbit.Client, allowDestructiv
 				err = client.DeleteTorrents(ctx, hashes, false)
 			case "delete_files":
 				err = client.DeleteTorrents(ctx, hashes, true)
-			default:
-				return mcp.NewToolResultError(fmt.Sprintf("unknown action %q (use: pause, resume, delete, delete_files)", action)), nil
 			}
+			g.record(ctx, req, pr, start, 0, err, nil)
 
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("action %s failed: %v", action, err)), nil
@@ -104,6 +143,46 @@ func registerQbitTools(s *server.MCPServer, client *qbit.Client, allowDestructiv
 	)
 }
 
+// qbitRequest describes a qBittorrent Web API call for the policy.
+func qbitRequest(endpoint string, body map[string]any) policy.Request {
+	return policy.Request{Service: "qbittorrent", Type: "qbittorrent", Method: "POST", Path: endpoint, Body: body}
+}
+
+// qbitDryRun renders the form POST behind pr without sending it.
+func qbitDryRun(ctx context.Context, client *qbit.Client, g *guard, pr policy.Request, what string) *mcp.CallToolResult {
+	form := url.Values{}
+	for k, v := range pr.Body.(map[string]any) {
+		form.Set(k, fmt.Sprint(v))
+	}
+	httpReq, err := client.Request(ctx, pr.Method, "/api/v2"+pr.Path, form)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	return g.render(pr, what, httpReq, nil)
+}
+
+// qbitPreview names the torrents an action would touch.
+func qbitPreview(client *qbit.Client, hashes []string) func(context.Context) any {
+	return func(ctx context.Context) any {
+		torrents, err := client.ListTorrents(ctx)
+		if err != nil {
+			return map[string]any{"hashes": hashes, "error": err.Error()}
+		}
+		all := len(hashes) == 1 && hashes[0] == "all"
+		want := make(map[string]bool, len(hashes))
+		for _, h := range hashes {
+			want[strings.ToLower(h)] = true
+		}
+		affects := []map[string]any{}
+		for _, t := range torrents {
+			if all || want[strings.ToLower(t.Hash)] {
+				affects = append(affects, map[string]any{"hash": t.Hash, "name": t.Name, "size": t.Size})
+			}
+		}
+		return affects
+	}
+}
+
 func parseHashes(s string) []string {
 	if s == "" {
 		return nil
@@ -1,26 +1,83 @@
 package tools
 
 import (
+	"context"
+	"sync"
+
 	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/auth"
 	"github.com/jakenesler/navigatorr/config"
 	"github.com/jakenesler/navigatorr/openapi"
+	"github.com/jakenesler/navigatorr/policy"
 	"github.com/jakenesler/navigatorr/qbit"
 	"github.com/jakenesler/navigatorr/sabnzbd"
 	"github.com/jakenesler/navigatorr/transmission"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-// RegisterAll registers all tools with the MCP server.
-func RegisterAll(s *server.MCPServer, cfg *config.Config, registry *arrservice.Registry, specStore *openapi.Store, txClient *transmission.Client, qbClient *qbit.Client, sabClient *sabnzbd.Client) {
+// State is what a running server keeps across config reloads: confirmation
+// tokens, the responses fetch_more pages through, paused pipelines, and the
+// call_api_batch rate limiters. The config file is polled every few seconds, so without it
+// any edit would void a preview the user is about to confirm.
+type State struct {
+	pending *confirmations
+	results *resultStore
+	paused  *pausedPipelines
+
+	mu     sync.Mutex
+	limits map[string]*rateLimiter
+}
+
+// NewState returns an empty State, to be created once per process and passed
+// to every RegisterAll.
+func NewState() *State {
+	return &State{pending: newConfirmations(), results: newResultStore(), paused: newPausedPipelines()}
+}
+
+// rateLimits returns the limiters for services, keeping a service's limiter,
+// and its place in the schedule, while its rate_limit is unchanged.
+func (st *State) rateLimits(services map[string]config.ServiceConfig) map[string]*rateLimiter {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	limits := rateLimits(services)
+	for name, l := range limits {
+		if old := st.limits[name]; old != nil && old.interval == l.interval {
+			limits[name] = old
+		}
+	}
+	st.limits = limits
+	return limits
+}
+
+// RegisterAll registers all tools with the MCP server. Tools registered again
+// with the same state, after a reload, carry on where the old ones left off.
+func RegisterAll(s *server.MCPServer, state *State, cfg *config.Config, registry *arrservice.Registry, specStore *openapi.Store, txClient *transmission.Client, qbClient *qbit.Client, sabClient *sabnzbd.Client, auditLog *audit.Log) {
+	g := newGuard(policy.New(cfg.Policy), cfg.AllowDestructive, cfg.ConfirmDestructive, auditLog, state)
+	g.dryRun = cfg.DryRun
 	registerDocTools(s, registry, specStore)
-	registerAPICallTool(s, registry, cfg.MaxResponseSizeKB, cfg.AllowDestructive)
+	registerAPICallTool(s, registry, specStore, cfg.MaxResponseTokens, g)
+	registerFetchMoreTool(s, cfg.MaxResponseTokens, g)
+	registerBatchTool(s, registry, specStore, cfg.MaxResponseTokens, cfg.BatchConcurrency, state.rateLimits(cfg.Services), g)
 	if txClient != nil {
-		registerTransmissionTools(s, txClient, cfg.AllowDestructive)
+		registerTransmissionTools(s, txClient, g)
 	}
 	if qbClient != nil {
-		registerQbitTools(s, qbClient, cfg.AllowDestructive)
+		registerQbitTools(s, qbClient, g)
 	}
 	if sabClient != nil {
-		registerSabnzbdTools(s, sabClient, cfg.AllowDestructive)
+		registerSabnzbdTools(s, sabClient, g)
 	}
+	if auditLog != nil {
+		registerAuditTools(s, auditLog)
+		registerUndoTool(s, registry, g)
+	}
+	// Last, so every tool a step can call is registered.
+	registerPipelineTool(s, s.ListTools(), state.paused)
+}
+
+// destructiveAllowed resolves allow_destructive for the caller. A network
+// client's own allow_destructive setting overrides the global one.
+func destructiveAllowed(ctx context.Context, global bool) bool {
+	return auth.FromContext(ctx).AllowDestructive(global)
 }
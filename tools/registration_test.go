@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// A config reload registers every tool again. A preview issued before it
+// must still be confirmable after it, and a rate limiter must keep its
+// schedule.
+func TestStateSurvivesReload(t *testing.T) {
+	deletes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deletes++
+		}
+		w.Write([]byte(`{"id": 7, "title": "Firefly"}`))
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{
+		AllowDestructive:   true,
+		ConfirmDestructive: true,
+		Services: map[string]config.ServiceConfig{
+			"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3", RateLimit: 5},
+		},
+	}
+	state := NewState()
+	register := func() *server.MCPServer {
+		s := server.NewMCPServer("test", "0.0.0")
+		RegisterAll(s, state, cfg, arrservice.NewRegistry(cfg), nil, nil, nil, nil, nil)
+		return s
+	}
+
+	args := map[string]any{"service": "sonarr", "method": "DELETE", "path": "/series/7"}
+	var preview confirmPreview
+	if err := json.Unmarshal([]byte(resultText(t, callTool(t, register(), "call_api", args))), &preview); err != nil || preview.Confirm == "" {
+		t.Fatalf("expected a preview with a token, got err=%v", err)
+	}
+	limiter := state.limits["sonarr"]
+
+	args["confirm"] = preview.Confirm
+	if res := callTool(t, register(), "call_api", args); res.IsError || deletes != 1 {
+		t.Fatalf("token from before the reload was refused (%d deletes): %s", deletes, resultText(t, res))
+	}
+	if state.limits["sonarr"] != limiter {
+		t.Error("the rate limiter was replaced although rate_limit did not change")
+	}
+
+	cfg.Services["sonarr"] = config.ServiceConfig{URL: srv.URL, RateLimit: 10}
+	register()
+	if state.limits["sonarr"] == limiter {
+		t.Error("the rate limiter was kept after rate_limit changed")
+	}
+}
@@ -1,10 +1,15 @@
 package tools
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"time"
 
+	"github.com/jakenesler/navigatorr/format"
+	"github.com/jakenesler/navigatorr/policy"
 	"github.com/jakenesler/navigatorr/sabnzbd"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -22,7 +27,68 @@ var sabPriorities = map[string]string{
 	"force":   "2",
 }
 
-func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDestructive bool) {
+// sabRequest describes a SABnzbd API call for the policy, with mode and name
+// as the path and the parameters Do would send as the query.
+func sabRequest(path string, params map[string]string) policy.Request {
+	query := url.Values{}
+	for k, v := range params {
+		if v != "" {
+			query.Set(k, v)
+		}
+	}
+	return policy.Request{Service: "sabnzbd", Type: "sabnzbd", Method: "GET", Path: path, Query: query}
+}
+
+// sabQueueCall maps a sabnzbd_manage_item action to the mode and parameters
+// SABnzbd takes for it.
+func sabQueueCall(action, nzoID, value string) (string, map[string]string, error) {
+	switch action {
+	case "pause", "resume", "delete":
+		return "queue", map[string]string{"name": action, "value": nzoID}, nil
+	case "delete_files":
+		return "queue", map[string]string{"name": "delete", "value": nzoID, "del_files": "1"}, nil
+	case "priority":
+		mapped, ok := sabPriorities[value]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown priority %q (use: default, stop, paused, low, normal, high, force)", value)
+		}
+		return "queue", map[string]string{"name": "priority", "value": nzoID, "value2": mapped}, nil
+	case "move":
+		if value == "" {
+			return "", nil, fmt.Errorf("move needs value set to a target job id or queue position")
+		}
+		return "switch", map[string]string{"value": nzoID, "value2": value}, nil
+	}
+	return "", nil, fmt.Errorf("unknown action %q (use: pause, resume, delete, delete_files, priority, move)", action)
+}
+
+// sabDryRun renders the mode query behind pr without sending it.
+func sabDryRun(ctx context.Context, client *sabnzbd.Client, g *guard, pr policy.Request, mode string, params map[string]string, what string) *mcp.CallToolResult {
+	httpReq, err := client.Request(ctx, mode, params)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	return g.render(pr, what, httpReq, nil)
+}
+
+// sabPreview names the queued jobs an action would touch.
+func sabPreview(client *sabnzbd.Client, nzoID string) func(context.Context) any {
+	return func(ctx context.Context) any {
+		queue, err := client.GetQueue(ctx, 0, 0, "", "")
+		if err != nil {
+			return map[string]any{"nzo_id": nzoID, "error": err.Error()}
+		}
+		affects := []map[string]any{}
+		for _, slot := range queue.Slots {
+			if nzoID == "all" || slot.NzoID == nzoID {
+				affects = append(affects, map[string]any{"nzo_id": slot.NzoID, "name": slot.Filename, "size": slot.Size})
+			}
+		}
+		return affects
+	}
+}
+
+func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, g *guard) {
 	// sabnzbd_list_queue
 	s.AddTool(
 		mcp.NewTool("sabnzbd_list_queue",
@@ -31,6 +97,8 @@ func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDest
 			mcp.WithNumber("start", mcp.Description("Offset into the queue")),
 			mcp.WithString("category", mcp.Description("Only jobs in this category")),
 			mcp.WithString("search", mcp.Description("Only jobs matching this term")),
+			withOutput(format.JSON),
+			withColumns(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			queue, err := client.GetQueue(ctx,
@@ -42,8 +110,7 @@ func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDest
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("failed to list queue: %v", err)), nil
 			}
-			data, _ := json.MarshalIndent(queue, "", "  ")
-			return mcp.NewToolResultText(string(data)), nil
+			return outputResult(req, queue), nil
 		},
 	)
 
@@ -56,6 +123,8 @@ func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDest
 			mcp.WithString("category", mcp.Description("Only entries in this category")),
 			mcp.WithString("search", mcp.Description("Only entries matching this term")),
 			mcp.WithBoolean("failed_only", mcp.Description("Only failed downloads")),
+			withOutput(format.JSON),
+			withColumns(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			history, err := client.GetHistory(ctx,
@@ -68,8 +137,7 @@ func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDest
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("failed to get history: %v", err)), nil
 			}
-			data, _ := json.MarshalIndent(history, "", "  ")
-			return mcp.NewToolResultText(string(data)), nil
+			return outputResult(req, history), nil
 		},
 	)
 
@@ -81,6 +149,8 @@ func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDest
 			mcp.WithString("name", mcp.Description("Job name to use instead of the one in the NZB")),
 			mcp.WithString("category", mcp.Description("Category to file the job under")),
 			mcp.WithString("priority", mcp.Description("default, stop, paused, low, normal, high, or force")),
+			withConfirm(),
+			withDryRun(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			nzbURL := mcp.ParseString(req, "url", "")
@@ -97,7 +167,19 @@ func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDest
 				priority = mapped
 			}
 
-			ids, err := client.AddURL(ctx, nzbURL, mcp.ParseString(req, "name", ""), mcp.ParseString(req, "category", ""), priority)
+			name, category := mcp.ParseString(req, "name", ""), mcp.ParseString(req, "category", "")
+			params := map[string]string{"name": nzbURL, "nzbname": name, "cat": category, "priority": priority}
+			pr := sabRequest("/addurl", params)
+			if g.dryRunning(req) {
+				return sabDryRun(ctx, client, g, pr, "addurl", params, "Adding"), nil
+			}
+			if refusal := g.check(ctx, req, pr, "Adding", nil); refusal != nil {
+				return refusal, nil
+			}
+
+			start := time.Now()
+			ids, err := client.AddURL(ctx, nzbURL, name, category, priority)
+			g.record(ctx, req, pr, start, 0, err, nil)
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("failed to add NZB: %v", err)), nil
 			}
@@ -112,6 +194,8 @@ func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDest
 			mcp.WithString("action", mcp.Required(), mcp.Description("Action: pause, resume, delete, delete_files, priority, move")),
 			mcp.WithString("nzo_id", mcp.Required(), mcp.Description("Job id, or \"all\" where SABnzbd accepts it")),
 			mcp.WithString("value", mcp.Description("Priority name for priority, or target job id or queue position for move")),
+			withConfirm(),
+			withDryRun(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			action := mcp.ParseString(req, "action", "")
@@ -122,37 +206,29 @@ func registerSabnzbdTools(s *server.MCPServer, client *sabnzbd.Client, allowDest
 				return mcp.NewToolResultError("nzo_id is required"), nil
 			}
 
-			// SABnzbd deletes are GET requests carrying name=delete, so the
-			// call_api DELETE guard never sees them. Gate them here instead.
-			if (action == "delete" || action == "delete_files") && !allowDestructive {
-				return mcp.NewToolResultError("Deleting is disabled. Set allow_destructive: true in config.yaml to enable."), nil
+			mode, params, err := sabQueueCall(action, nzoID, value)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
 			}
 
-			var (
-				body []byte
-				err  error
-			)
-			switch action {
-			case "pause", "resume":
-				body, err = client.QueueAction(ctx, action, nzoID, "")
-			case "delete":
-				body, err = client.QueueAction(ctx, "delete", nzoID, "")
-			case "delete_files":
-				body, err = client.Do(ctx, "queue", map[string]string{"name": "delete", "value": nzoID, "del_files": "1"})
-			case "priority":
-				mapped, ok := sabPriorities[value]
-				if !ok {
-					return mcp.NewToolResultError(fmt.Sprintf("unknown priority %q (use: default, stop, paused, low, normal, high, force)", value)), nil
-				}
-				body, err = client.QueueAction(ctx, "priority", nzoID, mapped)
-			case "move":
-				if value == "" {
-					return mcp.NewToolResultError("move needs value set to a target job id or queue position"), nil
-				}
-				body, err = client.Move(ctx, nzoID, value)
-			default:
-				return mcp.NewToolResultError(fmt.Sprintf("unknown action %q (use: pause, resume, delete, delete_files, priority, move)", action)), nil
+			// SABnzbd deletes are GET requests carrying name=delete, so a rule
+			// on the DELETE verb never sees them. The policy sees mode/name as
+			// the path instead, e.g. /queue/delete.
+			what := "Running " + action
+			if params["name"] == "delete" {
+				what = "Deleting"
+			}
+			pr := sabRequest("/queue/"+cmp.Or(params["name"], action), params)
+			if g.dryRunning(req) {
+				return sabDryRun(ctx, client, g, pr, mode, params, what), nil
 			}
+			if refusal := g.check(ctx, req, pr, what, sabPreview(client, nzoID)); refusal != nil {
+				return refusal, nil
+			}
+
+			start := time.Now()
+			body, err := client.Do(ctx, mode, params)
+			g.record(ctx, req, pr, start, 0, err, nil)
 
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("action %s failed: %v", action, err)), nil
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/openapi"
+)
+
+// specIndex returns a service's parsed spec, or nil when none is loaded.
+func specIndex(store *openapi.Store, service string) *openapi.Index {
+	if store == nil {
+		return nil
+	}
+	return store.GetIndex(service)
+}
+
+// sensitiveFields returns the property names a service's spec marks as
+// secret, on top of the names audit.Sensitive already catches.
+func sensitiveFields(store *openapi.Store, service string) map[string]bool {
+	if idx := specIndex(store, service); idx != nil {
+		return idx.Sensitive
+	}
+	return nil
+}
+
+// redactRaw masks secrets in a raw JSON body. Bodies that are not JSON, and
+// any body when redact is nil, are returned as they are.
+func redactRaw(body []byte, redact func(any) any) []byte {
+	var decoded any
+	if redact == nil || json.Unmarshal(body, &decoded) != nil {
+		return body
+	}
+	out, _ := json.Marshal(redact(decoded))
+	return out
+}
+
+// restoreMasked replaces each mask in a request body with the value stored
+// on the service, read from the resource the body updates.
+func restoreMasked(ctx context.Context, svc *arrservice.Service, path string, body any) (any, error) {
+	source := maskSource(path, body)
+	if source == "" {
+		return nil, fmt.Errorf("the body contains the secret mask %q, but %s does not name a saved resource to restore it from; send the real value instead", audit.Mask, path)
+	}
+	current := readSnapshot(ctx, svc, source)
+	if current.Error != "" {
+		return nil, fmt.Errorf("the body contains the secret mask %q, and reading %s to restore it failed: %s", audit.Mask, source, current.Error)
+	}
+	restored, ok := audit.Unmask(body, current.Before)
+	if !ok {
+		return nil, fmt.Errorf("the body contains the secret mask %q for a field %s has no value for; send the real value instead", audit.Mask, source)
+	}
+	return restored, nil
+}
+
+// maskSource names the resource whose stored secrets a body's masks stand
+// for: the path itself for PUT /downloadclient/3, or the body's id for calls
+// such as POST /downloadclient/test.
+func maskSource(path string, body any) string {
+	clean, segments, item := splitResourcePath(path)
+	if item {
+		return clean
+	}
+	obj, _ := body.(map[string]any)
+	if id, ok := obj["id"].(float64); ok && id > 0 {
+		return fmt.Sprintf("/%s/%d", segments[0], int(id))
+	}
+	return ""
+}
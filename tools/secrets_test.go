@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const downloadClient = `{"id":1,"name":"SAB","password":"hunter2","fields":[` +
+	`{"name":"host","value":"sab.local"},` +
+	`{"name":"apiKey","value":"abc123","privacy":"apiKey"},` +
+	`{"name":"urlBase","value":"/x","type":"password"}]}`
+
+// Responses are masked by default, can be revealed only where a policy rule
+// allows, and a masked value sent back is restored rather than stored.
+func TestCallAPIRedactsSecrets(t *testing.T) {
+	var putBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			data, _ := io.ReadAll(r.Body)
+			putBody = string(data)
+		}
+		w.Write([]byte(downloadClient))
+	}))
+	defer srv.Close()
+
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	g := newGuard(policy.New(config.PolicyConfig{RevealSecrets: []config.PolicyRule{
+		{Services: []string{"sonarr"}, Methods: []string{"GET"}, Path: "/downloadclient/*"},
+	}}), false, false, nil, NewState())
+	call := func(args map[string]any) *mcp.CallToolResult {
+		t.Helper()
+		req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: args}}
+		res, err := handleCallAPI(context.Background(), req, registry, nil, 12800, g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	masked := resultText(t, call(map[string]any{"service": "sonarr", "path": "/downloadclient/1"}))
+	for _, secret := range []string{"hunter2", "abc123", "/x"} {
+		if strings.Contains(masked, secret) {
+			t.Errorf("response leaked %q:\n%s", secret, masked)
+		}
+	}
+	if !strings.Contains(masked, "sab.local") {
+		t.Errorf("ordinary fields should be untouched:\n%s", masked)
+	}
+
+	if res := call(map[string]any{"service": "sonarr", "path": "/config/host", "reveal_secrets": true}); !res.IsError {
+		t.Error("reveal_secrets should be refused without a matching policy rule")
+	}
+	if text := resultText(t, call(map[string]any{"service": "sonarr", "path": "/downloadclient/1", "reveal_secrets": true})); !strings.Contains(text, "abc123") {
+		t.Errorf("reveal_secrets allowed by policy should return raw values:\n%s", text)
+	}
+
+	// Send the masked object straight back, with one ordinary change.
+	var obj map[string]any
+	json.Unmarshal([]byte(masked), &obj)
+	obj["name"] = "SABnzbd"
+	body, _ := json.Marshal(obj)
+	if res := call(map[string]any{"service": "sonarr", "method": "PUT", "path": "/downloadclient/1", "body": string(body)}); res.IsError {
+		t.Fatalf("PUT: %s", resultText(t, res))
+	}
+	for _, want := range []string{`"password":"hunter2"`, `"value":"abc123"`, `"name":"SABnzbd"`} {
+		if !strings.Contains(putBody, want) {
+			t.Errorf("PUT body should carry %s, got %s", want, putBody)
+		}
+	}
+
+	if res := call(map[string]any{"service": "sonarr", "method": "POST", "path": "/downloadclient", "body": `{"password":"********"}`}); !res.IsError {
+		t.Error("a mask with no resource to restore it from should be refused")
+	}
+}
@@ -0,0 +1,361 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jakenesler/navigatorr/format"
+	"github.com/jmespath/go-jmespath"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// shape is how call_api trims an array response before the size guard sees
+// it: which items, grouped how, in what order, how many, and which fields of
+// each. expr, when set, reshapes the whole response first and the rest apply
+// to what it returns.
+type shape struct {
+	expr    *jmespath.JMESPath
+	fields  string
+	filter  filterExpr
+	sort    []sortKey
+	groupBy []string
+	aggs    []aggregate
+	limit   int
+}
+
+// empty reports whether the response passes through untouched.
+func (s shape) empty() bool {
+	return s.fields == "" && s.filter == nil && len(s.sort) == 0 && len(s.groupBy) == 0 && len(s.aggs) == 0 && s.limit == 0
+}
+
+// grouped reports whether items are summarised into rows.
+func (s shape) grouped() bool {
+	return len(s.groupBy) > 0 || len(s.aggs) > 0
+}
+
+// output writes a shaped response in f. A table's columns are the fields
+// asked for; grouped rows already hold just their columns.
+func (s shape) output(f format.Format) output {
+	out := output{format: f}
+	if !s.grouped() {
+		out.columns = parseFields(s.fields)
+	}
+	return out
+}
+
+// shapeArgs are the arguments parseShape reads.
+var shapeArgs = []string{"expr", "fields", "filter", "sort", "group_by", "aggregate", "limit"}
+
+// applyShape evaluates the expression, then the other shaping, over a decoded
+// response.
+func applyShape(resp any, s shape) (any, error) {
+	if s.expr != nil {
+		result, err := s.expr.Search(resp)
+		if err != nil {
+			return nil, fmt.Errorf("expr failed: %v", err)
+		}
+		resp = result
+	}
+	// Filter, sort, group and trim before the size guard, so a summary of a
+	// huge list still fits.
+	if !s.empty() {
+		resp = processResponse(resp, s)
+	}
+	return resp, nil
+}
+
+// parseShape reads the response-shaping arguments, so a malformed one is
+// reported before the call is made.
+func parseShape(req mcp.CallToolRequest) (shape, error) {
+	var s shape
+	var err error
+	if s.expr, err = parseExpr(mcp.ParseString(req, "expr", "")); err != nil {
+		return s, err
+	}
+	s.fields = mcp.ParseString(req, "fields", "")
+	if s.filter, err = parseFilter(mcp.ParseString(req, "filter", "")); err != nil {
+		return s, fmt.Errorf("invalid filter: %v", err)
+	}
+	if s.sort, err = parseSort(mcp.ParseString(req, "sort", "")); err != nil {
+		return s, err
+	}
+	s.groupBy = parseFields(mcp.ParseString(req, "group_by", ""))
+	if s.aggs, err = parseAggregates(mcp.ParseString(req, "aggregate", "")); err != nil {
+		return s, err
+	}
+	if len(s.groupBy) > 0 && len(s.aggs) == 0 {
+		s.aggs = []aggregate{{fn: "count"}}
+	}
+	// An unusable limit has always been ignored rather than refused.
+	if limit, err := strconv.Atoi(mcp.ParseString(req, "limit", "")); err == nil && limit > 0 {
+		s.limit = limit
+	}
+	return s, nil
+}
+
+// parseExpr compiles a JMESPath expression. A syntax error points at where
+// parsing stopped, which is easier to act on than the message alone.
+func parseExpr(s string) (*jmespath.JMESPath, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	jp, err := jmespath.Compile(s)
+	if err != nil {
+		var syntax jmespath.SyntaxError
+		if errors.As(err, &syntax) {
+			return nil, fmt.Errorf("invalid expr: %v\n%s", err, syntax.HighlightLocation())
+		}
+		return nil, fmt.Errorf("invalid expr: %v", err)
+	}
+	return jp, nil
+}
+
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// parseSort reads "field:desc,other" or "-field,other". Keys default to
+// ascending.
+func parseSort(s string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, part := range parseFields(s) {
+		k := sortKey{field: part}
+		if rest, ok := strings.CutPrefix(part, "-"); ok {
+			k.field, k.desc = rest, true
+		}
+		if field, dir, ok := strings.Cut(k.field, ":"); ok {
+			k.field = field
+			switch strings.ToLower(dir) {
+			case "asc":
+			case "desc":
+				k.desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort %q: direction must be asc or desc", part)
+			}
+		}
+		if k.field == "" {
+			return nil, fmt.Errorf("invalid sort %q: missing field", part)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// aggregate is one summary column: count, or fn:field for the others.
+type aggregate struct {
+	fn    string
+	field string
+}
+
+// column names the aggregate in result rows, e.g. sum(sizeOnDisk).
+func (a aggregate) column() string {
+	if a.field == "" {
+		return a.fn
+	}
+	return a.fn + "(" + a.field + ")"
+}
+
+// parseAggregates reads "count,sum:sizeOnDisk,distinct:genres".
+func parseAggregates(s string) ([]aggregate, error) {
+	var aggs []aggregate
+	for _, part := range parseFields(s) {
+		fn, field, _ := strings.Cut(part, ":")
+		a := aggregate{fn: strings.ToLower(fn), field: field}
+		switch a.fn {
+		case "count":
+		case "sum", "min", "max", "avg", "distinct":
+			if a.field == "" {
+				return nil, fmt.Errorf("invalid aggregate %q: %s needs a field, as in %s:sizeOnDisk", part, a.fn, a.fn)
+			}
+		default:
+			return nil, fmt.Errorf("invalid aggregate %q (use: count, sum, min, max, avg, distinct)", part)
+		}
+		aggs = append(aggs, a)
+	}
+	return aggs, nil
+}
+
+// fieldValue follows a dotted path through nested objects. A key spelled
+// exactly as the path wins, which is how grouped rows name their columns.
+func fieldValue(v any, path string) any {
+	if obj, ok := v.(map[string]any); ok {
+		if val, ok := obj[path]; ok {
+			return val
+		}
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+// compareValues orders numbers numerically, booleans false first, and
+// everything else as case-insensitive text, which also orders ISO dates.
+func compareValues(a, b any) int {
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			return compareFloat(x, y)
+		}
+	}
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(strings.ToLower(formatValue(a)), strings.ToLower(formatValue(b)))
+}
+
+// sortItems orders items by the keys in turn. Items missing a key sort
+// after those that have it, whichever the direction. The sort is stable, so
+// equal items keep the service's order.
+func sortItems(items []any, keys []sortKey) {
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, k := range keys {
+			a, b := fieldValue(items[i], k.field), fieldValue(items[j], k.field)
+			switch {
+			case a == nil && b == nil:
+				continue
+			case a == nil:
+				return false
+			case b == nil:
+				return true
+			}
+			c := compareValues(a, b)
+			if c == 0 {
+				continue
+			}
+			return c < 0 != k.desc
+		}
+		return false
+	})
+}
+
+// groupItems summarises items into one row per distinct combination of the
+// group_by fields, or a single row without them. Each row holds the group
+// values under their field names and each aggregate under its column name.
+// Rows come out in the order their groups first appear.
+func groupItems(items []any, groupBy []string, aggs []aggregate) []any {
+	type group struct {
+		row   map[string]any
+		items []any
+	}
+	var order []string
+	groups := map[string]*group{}
+	for _, item := range items {
+		keyParts := make([]string, len(groupBy))
+		for i, f := range groupBy {
+			keyParts[i] = formatValue(fieldValue(item, f))
+		}
+		key := strings.Join(keyParts, "\x00")
+		g, ok := groups[key]
+		if !ok {
+			g = &group{row: map[string]any{}}
+			for _, f := range groupBy {
+				g.row[f] = fieldValue(item, f)
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.items = append(g.items, item)
+	}
+	if len(groupBy) == 0 && len(groups) == 0 {
+		// Aggregates over nothing still answer, with a count of 0.
+		groups[""] = &group{row: map[string]any{}}
+		order = append(order, "")
+	}
+
+	rows := make([]any, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		for _, a := range aggs {
+			g.row[a.column()] = a.apply(g.items)
+		}
+		rows = append(rows, g.row)
+	}
+	return rows
+}
+
+// apply computes the aggregate over a group. sum and avg skip values that
+// are not numbers; min and max compare like sort; distinct lists each value
+// once, looking inside arrays, so distinct:genres names every genre.
+func (a aggregate) apply(items []any) any {
+	// Counts are float64 like every other decoded JSON number, so rows sort
+	// on them numerically.
+	if a.fn == "count" {
+		if a.field == "" {
+			return float64(len(items))
+		}
+		n := 0.0
+		for _, item := range items {
+			if fieldValue(item, a.field) != nil {
+				n++
+			}
+		}
+		return n
+	}
+
+	var values []any
+	for _, item := range items {
+		v := fieldValue(item, a.field)
+		if arr, ok := v.([]any); ok && a.fn == "distinct" {
+			values = append(values, arr...)
+		} else if v != nil {
+			values = append(values, v)
+		}
+	}
+
+	switch a.fn {
+	case "sum", "avg":
+		sum, n := 0.0, 0
+		for _, v := range values {
+			if f, ok := v.(float64); ok {
+				sum += f
+				n++
+			}
+		}
+		if a.fn == "sum" {
+			return sum
+		}
+		if n == 0 {
+			return nil
+		}
+		return sum / float64(n)
+	case "min", "max":
+		var best any
+		for _, v := range values {
+			if best == nil {
+				best = v
+				continue
+			}
+			if c := compareValues(v, best); a.fn == "min" && c < 0 || a.fn == "max" && c > 0 {
+				best = v
+			}
+		}
+		return best
+	}
+
+	seen := map[string]bool{}
+	distinct := []any{}
+	for _, v := range values {
+		if k := formatValue(v); !seen[k] {
+			seen[k] = true
+			distinct = append(distinct, v)
+		}
+	}
+	sort.SliceStable(distinct, func(i, j int) bool { return compareValues(distinct[i], distinct[j]) < 0 })
+	return distinct
+}
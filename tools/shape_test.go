@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const movies = `[
+  {"title": "Alpha", "year": 2001, "studio": "A24", "genres": ["Drama"], "statistics": {"sizeOnDisk": 5000}},
+  {"title": "beta", "year": 2020, "studio": "Pixar", "genres": ["Animation", "Comedy"], "statistics": {"sizeOnDisk": 90000}},
+  {"title": "Gamma", "year": 2020, "studio": "A24", "genres": ["Drama", "Horror"]},
+  {"title": "Delta", "year": 2015, "studio": "A24", "genres": [], "statistics": {"sizeOnDisk": 700}}
+]`
+
+func TestCallAPISortsAndGroups(t *testing.T) {
+	serve := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(movies)) }
+	rows := func(args map[string]any) []map[string]any {
+		t.Helper()
+		args["path"] = "/movie"
+		res := callAPI(t, serve, args)
+		var out []map[string]any
+		if err := json.Unmarshal([]byte(resultText(t, res)), &out); err != nil {
+			t.Fatalf("%v: %s", err, resultText(t, res))
+		}
+		return out
+	}
+	titles := func(rows []map[string]any) string {
+		var names []string
+		for _, r := range rows {
+			names = append(names, r["title"].(string))
+		}
+		return strings.Join(names, ",")
+	}
+
+	// Missing values sort last either way; text sorts without regard to case.
+	if got := titles(rows(map[string]any{"sort": "statistics.sizeOnDisk:desc", "limit": "3"})); got != "beta,Alpha,Delta" {
+		t.Errorf("biggest first = %s", got)
+	}
+	if got := titles(rows(map[string]any{"sort": "-year,title"})); got != "beta,Gamma,Delta,Alpha" {
+		t.Errorf("multi-key sort = %s", got)
+	}
+
+	got := rows(map[string]any{"group_by": "studio", "aggregate": "count,sum:statistics.sizeOnDisk,max:year", "sort": "count:desc"})
+	if len(got) != 2 || got[0]["studio"] != "A24" || got[0]["count"] != 3.0 || got[0]["sum(statistics.sizeOnDisk)"] != 5700.0 || got[0]["max(year)"] != 2020.0 {
+		t.Errorf("grouped rows = %v", got)
+	}
+
+	got = rows(map[string]any{"aggregate": "avg:year,distinct:genres,min:title", "filter": "studio:eq:A24"})
+	distinct, _ := json.Marshal(got[0]["distinct(genres)"])
+	if len(got) != 1 || got[0]["avg(year)"] != 2012.0 || string(distinct) != `["Drama","Horror"]` || got[0]["min(title)"] != "Alpha" {
+		t.Errorf("whole-array aggregates = %v", got)
+	}
+}
+
+func TestParseShapeErrors(t *testing.T) {
+	for _, tt := range []struct{ arg, value, want string }{
+		{"sort", "title:up", "asc or desc"},
+		{"aggregate", "median:year", "use: count, sum"},
+		{"aggregate", "sum", "needs a field"},
+	} {
+		res := callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("%s=%s reached the service", tt.arg, tt.value)
+		}, map[string]any{"path": "/movie", tt.arg: tt.value})
+		if !res.IsError || !strings.Contains(resultText(t, res), tt.want) {
+			t.Errorf("%s=%s: %s", tt.arg, tt.value, resultText(t, res))
+		}
+	}
+}
+
+func TestCallAPIExpr(t *testing.T) {
+	queue := `{"page": 1, "records": [
+	  {"series": {"title": "Andor"}, "quality": {"quality": {"name": "WEBDL-1080p"}}, "apiKey": "abc"},
+	  {"series": {"title": "Severance"}, "quality": {"quality": {"name": "Bluray-2160p"}}}
+	]}`
+	for _, tt := range []struct {
+		name string
+		resp string
+		args map[string]any
+		want string
+	}{
+		{"flattens nested fields", queue, map[string]any{"expr": "records[].{series: series.title, quality: quality.quality.name}"},
+			`[{"quality":"WEBDL-1080p","series":"Andor"},{"quality":"Bluray-2160p","series":"Severance"}]`},
+		{"runs before sort and limit", movies, map[string]any{"expr": "[?studio=='A24'].{title: title, year: year}", "sort": "-year", "limit": "1"},
+			`[{"title":"Gamma","year":2020}]`},
+		{"scalar result", movies, map[string]any{"expr": "length(@)"}, `4`},
+		{"sees masked secrets", queue, map[string]any{"expr": "records[0].apiKey"}, `"********"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args["path"] = "/queue"
+			res := callAPI(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(tt.resp)) }, tt.args)
+			var got any
+			if err := json.Unmarshal([]byte(resultText(t, res)), &got); err != nil {
+				t.Fatalf("%v: %s", err, resultText(t, res))
+			}
+			if b, _ := json.Marshal(got); string(b) != tt.want {
+				t.Errorf("got %s, want %s", b, tt.want)
+			}
+		})
+	}
+
+	// Syntax errors are caught before the call and point at the problem;
+	// evaluation errors come back from the response.
+	res := callAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a malformed expr reached the service")
+	}, map[string]any{"path": "/movie", "expr": "records[?"})
+	if !res.IsError || !strings.Contains(resultText(t, res), "invalid expr") || !strings.Contains(resultText(t, res), "^") {
+		t.Errorf("syntax error = %s", resultText(t, res))
+	}
+	res = callAPI(t, func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(movies)) }, map[string]any{"path": "/movie", "expr": "sum([].title)"})
+	if !res.IsError || !strings.Contains(resultText(t, res), "expr failed") {
+		t.Errorf("evaluation error = %s", resultText(t, res))
+	}
+}
@@ -5,25 +5,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/jakenesler/navigatorr/format"
+	"github.com/jakenesler/navigatorr/policy"
 	"github.com/jakenesler/navigatorr/transmission"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-func registerTransmissionTools(s *server.MCPServer, client *transmission.Client, allowDestructive bool) {
+func registerTransmissionTools(s *server.MCPServer, client *transmission.Client, g *guard) {
 	// transmission_list_torrents
 	s.AddTool(
 		mcp.NewTool("transmission_list_torrents",
 			mcp.WithDescription("List all torrents with their status, progress, and download info"),
+			withOutput(format.JSON),
+			withColumns(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			torrents, err := client.TorrentGet(ctx)
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("failed to list torrents: %v", err)), nil
 			}
-			data, _ := json.MarshalIndent(torrents, "", "  ")
-			return mcp.NewToolResultText(string(data)), nil
+			return outputResult(req, torrents), nil
 		},
 	)
 
@@ -33,12 +37,28 @@ func registerTransmissionTools(s *server.MCPServer, client *transmission.Client,
 			mcp.WithDescription("Add a torrent by magnet link or URL"),
 			mcp.WithString("url", mcp.Required(), mcp.Description("Magnet link or torrent URL")),
 			mcp.WithString("download_dir", mcp.Description("Download directory (optional)")),
+			withConfirm(),
+			withDryRun(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			url := mcp.ParseString(req, "url", "")
 			downloadDir := mcp.ParseString(req, "download_dir", "")
 
+			args := map[string]any{"filename": url}
+			if downloadDir != "" {
+				args["download-dir"] = downloadDir
+			}
+			pr := transmissionRequest("torrent-add", args)
+			if g.dryRunning(req) {
+				return transmissionDryRun(ctx, client, g, pr, "Adding"), nil
+			}
+			if refusal := g.check(ctx, req, pr, "Adding", nil); refusal != nil {
+				return refusal, nil
+			}
+
+			start := time.Now()
 			info, err := client.TorrentAdd(ctx, url, downloadDir)
+			g.record(ctx, req, pr, start, 0, err, nil)
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("failed to add torrent: %v", err)), nil
 			}
@@ -53,6 +73,8 @@ func registerTransmissionTools(s *server.MCPServer, client *transmission.Client,
 			mcp.WithDescription("Manage a torrent: start, stop, remove, or verify"),
 			mcp.WithString("action", mcp.Required(), mcp.Description("Action: start, stop, remove, remove_data, verify")),
 			mcp.WithString("ids", mcp.Required(), mcp.Description("Comma-separated torrent IDs (e.g. \"1,2,3\")")),
+			withConfirm(),
+			withDryRun(),
 		),
 		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			action := mcp.ParseString(req, "action", "")
@@ -64,11 +86,26 @@ func registerTransmissionTools(s *server.MCPServer, client *transmission.Client,
 			}
 
 			// Transmission removals ride on torrent-remove in the RPC body, so
-			// the DELETE-verb guard in call_api never sees them either.
-			if (action == "remove" || action == "remove_data") && !allowDestructive {
-				return mcp.NewToolResultError("Removing is disabled. Set allow_destructive: true in config.yaml to enable."), nil
+			// the policy sees the RPC method as the path.
+			method, what := "torrent-"+action, "Running "+action
+			body := map[string]any{"ids": ids}
+			switch action {
+			case "start", "stop", "verify":
+			case "remove", "remove_data":
+				method, what = "torrent-remove", "Removing"
+				body["delete-local-data"] = action == "remove_data"
+			default:
+				return mcp.NewToolResultError(fmt.Sprintf("unknown action %q (use: start, stop, remove, remove_data, verify)", action)), nil
+			}
+			pr := transmissionRequest(method, body)
+			if g.dryRunning(req) {
+				return transmissionDryRun(ctx, client, g, pr, what), nil
+			}
+			if refusal := g.check(ctx, req, pr, what, transmissionPreview(client, ids)); refusal != nil {
+				return refusal, nil
 			}
 
+			start := time.Now()
 			switch action {
 			case "start":
 				err = client.TorrentStart(ctx, ids)
@@ -80,9 +117,8 @@ func registerTransmissionTools(s *server.MCPServer, client *transmission.Client,
 				err = client.TorrentRemove(ctx, ids, true)
 			case "verify":
 				err = client.TorrentVerify(ctx, ids)
-			default:
-				return mcp.NewToolResultError(fmt.Sprintf("unknown action %q (use: start, stop, remove, remove_data, verify)", action)), nil
 			}
+			g.record(ctx, req, pr, start, 0, err, nil)
 
 			if err != nil {
 				return mcp.NewToolResultError(fmt.Sprintf("action %s failed: %v", action, err)), nil
@@ -111,6 +147,42 @@ func registerTransmissionTools(s *server.MCPServer, client *transmission.Client,
 	)
 }
 
+// transmissionRequest describes a Transmission RPC call for the policy, with
+// the RPC method name as the path.
+func transmissionRequest(method string, arguments map[string]any) policy.Request {
+	return policy.Request{Service: "transmission", Type: "transmission", Method: "POST", Path: "/" + method, Body: arguments}
+}
+
+// transmissionDryRun renders the RPC request behind pr without sending it.
+func transmissionDryRun(ctx context.Context, client *transmission.Client, g *guard, pr policy.Request, what string) *mcp.CallToolResult {
+	httpReq, err := client.Request(ctx, strings.TrimPrefix(pr.Path, "/"), pr.Body)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+	return g.render(pr, what, httpReq, nil)
+}
+
+// transmissionPreview names the torrents an action would touch.
+func transmissionPreview(client *transmission.Client, ids []int) func(context.Context) any {
+	return func(ctx context.Context) any {
+		torrents, err := client.TorrentGet(ctx)
+		if err != nil {
+			return map[string]any{"ids": ids, "error": err.Error()}
+		}
+		want := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			want[id] = true
+		}
+		affects := []map[string]any{}
+		for _, t := range torrents {
+			if want[t.ID] {
+				affects = append(affects, map[string]any{"id": t.ID, "name": t.Name, "size": t.TotalSize})
+			}
+		}
+		return affects
+	}
+}
+
 func parseIDs(s string) ([]int, error) {
 	if s == "" {
 		return nil, fmt.Errorf("ids is required")
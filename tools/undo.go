@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// snapshotTargets lists the resources a PUT or PATCH would overwrite: the
+// resource itself for /series/12, and the items a bulk editor edits, listed in
+// ids or <resource>Ids: seriesIds for /series/editor, episodeFileIds for
+// /episodefile/editor. Other *Id fields in an editor body, such as
+// qualityProfileId, are values being set, not items being changed.
+func snapshotTargets(path string, body any) []string {
+	clean, segments, item := splitResourcePath(path)
+	if item {
+		return []string{clean}
+	}
+	if !strings.EqualFold(segments[len(segments)-1], "editor") {
+		return nil
+	}
+
+	// Paths are lower case and body keys camel case: /episodefile/editor
+	// takes episodeFileIds.
+	obj, _ := body.(map[string]any)
+	resource := segments[0]
+	var targets []string
+	for _, k := range slices.Sorted(maps.Keys(obj)) {
+		if k != "ids" && !(strings.HasSuffix(k, "Ids") && strings.EqualFold(strings.TrimSuffix(k, "Ids"), resource)) {
+			continue
+		}
+		_, ids := idsFromField(k, obj[k])
+		for _, id := range ids {
+			targets = append(targets, "/"+resource+"/"+id)
+		}
+	}
+	return targets
+}
+
+// takeSnapshots reads the current state of each target. A target that cannot
+// be read is kept with its error, so undo_change can say what it could not
+// restore rather than silently skipping it.
+func takeSnapshots(ctx context.Context, svc *arrservice.Service, targets []string) []audit.Snapshot {
+	snapshots := make([]audit.Snapshot, 0, len(targets))
+	for _, t := range targets {
+		snapshots = append(snapshots, readSnapshot(ctx, svc, t))
+	}
+	return snapshots
+}
+
+func readSnapshot(ctx context.Context, svc *arrservice.Service, path string) audit.Snapshot {
+	snap := audit.Snapshot{Path: path}
+	resp, status, err := svc.DoRequest(ctx, "GET", path, nil, nil)
+	switch {
+	case err != nil:
+		snap.Error = err.Error()
+	case status < 200 || status > 299:
+		snap.Error = fmt.Sprintf("HTTP %d", status)
+	default:
+		if err := json.Unmarshal(resp, &snap.Before); err != nil {
+			snap.Error = "response is not JSON"
+		}
+	}
+	return snap
+}
+
+// undoResult reports what undo_change did with one snapshot.
+type undoResult struct {
+	Path    string       `json:"path"`
+	Status  int          `json:"status,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Request *sentRequest `json:"request,omitempty"` // what a dry run would send
+}
+
+func registerUndoTool(s *server.MCPServer, registry *arrservice.Registry, g *guard) {
+	// undo_change
+	s.AddTool(
+		mcp.NewTool("undo_change",
+			mcp.WithDescription("Restore the state a call_api PUT or PATCH overwrote, by its audit entry ID. Every resource the call changed is written back with a PUT, including each item of a bulk editor call. The undo is itself audited, so it can be undone too"),
+			mcp.WithString("audit_id", mcp.Required(), mcp.Description("ID of the audit entry to revert, from call_api's output or audit_log")),
+			withDryRun(),
+		),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			id := mcp.ParseString(req, "audit_id", "")
+			entry, ok := g.audit.Get(id)
+			if !ok {
+				return mcp.NewToolResultError(fmt.Sprintf("no audit entry %q", id)), nil
+			}
+			if len(entry.Snapshots) == 0 {
+				return mcp.NewToolResultError(fmt.Sprintf("audit entry %s has no saved state; only call_api PUT and PATCH requests to a resource or bulk editor are snapshotted", id)), nil
+			}
+			if !auth.FromContext(ctx).AllowsService(entry.Service) {
+				return mcp.NewToolResultError(fmt.Sprintf("service %q is not allowed for this client", entry.Service)), nil
+			}
+			svc, err := registry.Get(entry.Service)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			results := make([]undoResult, 0, len(entry.Snapshots))
+			for _, snap := range entry.Snapshots {
+				results = append(results, undoSnapshot(ctx, req, g, svc, entry.ID, snap))
+			}
+			data, _ := json.MarshalIndent(results, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
+		},
+	)
+}
+
+// undoSnapshot writes one saved state back. Secrets were masked when the
+// snapshot was stored, so they are filled in from the resource's current
+// state, which is also kept as the undo's own snapshot.
+func undoSnapshot(ctx context.Context, req mcp.CallToolRequest, g *guard, svc *arrservice.Service, auditID string, snap audit.Snapshot) undoResult {
+	res := undoResult{Path: snap.Path}
+	if snap.Error != "" {
+		res.Error = "state was not saved: " + snap.Error
+		return res
+	}
+
+	current := readSnapshot(ctx, svc, snap.Path)
+	if current.Error != "" {
+		res.Error = "reading current state: " + current.Error
+		return res
+	}
+	before, ok := audit.Unmask(snap.Before, current.Before)
+	if !ok {
+		res.Error = "a masked secret has no current value to restore it from"
+		return res
+	}
+
+	pr := policy.Request{Service: svc.Name, Type: svc.Config.ServiceType(svc.Name), Method: "PUT", Path: snap.Path, Body: before}
+	what := "Undoing PUT " + snap.Path
+	body, _ := json.Marshal(before)
+	if g.dryRunning(req) {
+		_, res.Error = g.decide(ctx, pr, what)
+		httpReq, err := svc.NewRequest(ctx, "PUT", snap.Path, nil, body)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		sent := describeRequest(httpReq)
+		res.Request = &sent
+		return res
+	}
+	if refusal := g.check(ctx, req, pr, what, nil); refusal != nil {
+		res.Error = resultMessage(refusal)
+		return res
+	}
+
+	start := time.Now()
+	_, status, err := svc.DoRequest(ctx, "PUT", snap.Path, nil, body)
+	e := entryFor(ctx, req, pr, start, status, err, []audit.Snapshot{current})
+	e.Undoes = auditID
+	g.write(ctx, e)
+	res.Status = status
+	if err != nil {
+		res.Error = err.Error()
+	} else if status < 200 || status > 299 {
+		res.Error = fmt.Sprintf("HTTP %d", status)
+	}
+	return res
+}
+
+// resultMessage returns the text of a tool result.
+func resultMessage(res *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			parts = append(parts, tc.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
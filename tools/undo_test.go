@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/arrservice"
+	"github.com/jakenesler/navigatorr/audit"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/jakenesler/navigatorr/policy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// fakeStore is a tiny *arr stand-in holding JSON resources by path. PUT
+// replaces a resource; PUT /series/editor applies its fields to each id.
+type fakeStore struct {
+	mu   sync.Mutex
+	docs map[string]map[string]any
+	puts []string
+}
+
+func (f *fakeStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/api/v3")
+	switch r.Method {
+	case "GET":
+		doc, ok := f.docs[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(doc)
+	case "PUT":
+		f.puts = append(f.puts, path)
+		data, _ := io.ReadAll(r.Body)
+		var doc map[string]any
+		json.Unmarshal(data, &doc)
+		if path == "/series/editor" {
+			for _, id := range doc["seriesIds"].([]any) {
+				target := f.docs["/series/"+jsonNumber(id)]
+				for k, v := range doc {
+					if k != "seriesIds" {
+						target[k] = v
+					}
+				}
+			}
+		} else {
+			f.docs[path] = doc
+		}
+		w.Write(data)
+	}
+}
+
+func jsonNumber(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func undoFixture(t *testing.T, docs map[string]map[string]any) (*fakeStore, *server.MCPServer, *arrservice.Registry, *guard) {
+	t.Helper()
+	store := &fakeStore{docs: docs}
+	srv := httptest.NewServer(store)
+	t.Cleanup(srv.Close)
+
+	log := audit.New(config.AuditConfig{Path: filepath.Join(t.TempDir(), "audit.jsonl")})
+	registry := arrservice.NewRegistry(&config.Config{Services: map[string]config.ServiceConfig{
+		"sonarr": {URL: srv.URL, APIKey: "k", AuthMethod: "header", AuthHeader: "X-Api-Key", APIVersion: "/api/v3"},
+	}})
+	g := newGuard(policy.New(config.PolicyConfig{}), false, false, log, NewState())
+	s := server.NewMCPServer("test", "0.0.0")
+	registerUndoTool(s, registry, g)
+	return store, s, registry, g
+}
+
+// auditIDFrom pulls the audit entry ID out of call_api's undo note.
+func auditIDFrom(t *testing.T, res *mcp.CallToolResult) string {
+	t.Helper()
+	text := resultMessage(res)
+	_, rest, ok := strings.Cut(text, "audit entry ")
+	if !ok {
+		t.Fatalf("call_api did not report a saved state:\n%s", text)
+	}
+	return strings.TrimSuffix(strings.Fields(rest)[0], ".")
+}
+
+func TestUndoChangeRestoresResource(t *testing.T) {
+	store, s, registry, g := undoFixture(t, map[string]map[string]any{
+		"/series/1": {"id": 1.0, "title": "Andor", "monitored": true, "apiKey": "secret"},
+	})
+	before := store.docs["/series/1"]
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: map[string]any{
+		"service": "sonarr", "method": "PUT", "path": "/series/1",
+		"body": `{"id":1,"title":"Andor","monitored":false,"apiKey":"secret"}`,
+	}}}
+	res, err := handleCallAPI(context.Background(), req, registry, nil, 12800, g)
+	if err != nil || res.IsError {
+		t.Fatalf("PUT: %v %s", err, resultMessage(res))
+	}
+	id := auditIDFrom(t, res)
+	if store.docs["/series/1"]["monitored"] != false {
+		t.Fatal("PUT did not reach the fake service")
+	}
+
+	text := resultText(t, callTool(t, s, "undo_change", map[string]any{"audit_id": id}))
+	var results []undoResult
+	if err := json.Unmarshal([]byte(text), &results); err != nil || len(results) != 1 || results[0].Error != "" {
+		t.Fatalf("undo_change output: %v\n%s", err, text)
+	}
+	if !reflect.DeepEqual(store.docs["/series/1"], before) {
+		t.Errorf("state after undo = %v, want %v (masked apiKey must be restored)", store.docs["/series/1"], before)
+	}
+
+	entries, _ := g.audit.Query(audit.Filter{Tool: "undo_change"})
+	if len(entries) != 1 || entries[0].Undoes != id || len(entries[0].Snapshots) != 1 {
+		t.Errorf("undo should be audited with a link to %s and its own snapshot: %+v", id, entries)
+	}
+}
+
+func TestUndoChangeRestoresBulkEdit(t *testing.T) {
+	store, s, registry, g := undoFixture(t, map[string]map[string]any{
+		"/series/1": {"id": 1.0, "qualityProfileId": 1.0},
+		"/series/2": {"id": 2.0, "qualityProfileId": 4.0},
+	})
+
+	req := mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "call_api", Arguments: map[string]any{
+		"service": "sonarr", "method": "PUT", "path": "/series/editor",
+		"body": `{"seriesIds":[1,2],"qualityProfileId":7}`,
+	}}}
+	res, err := handleCallAPI(context.Background(), req, registry, nil, 12800, g)
+	if err != nil || res.IsError {
+		t.Fatalf("PUT: %v %s", err, resultMessage(res))
+	}
+	id := auditIDFrom(t, res)
+
+	store.puts = nil
+	callTool(t, s, "undo_change", map[string]any{"audit_id": id})
+	sort.Strings(store.puts)
+	if !reflect.DeepEqual(store.puts, []string{"/series/1", "/series/2"}) {
+		t.Errorf("undo PUTs = %v, want each edited series", store.puts)
+	}
+	if store.docs["/series/1"]["qualityProfileId"] != 1.0 || store.docs["/series/2"]["qualityProfileId"] != 4.0 {
+		t.Errorf("profiles not restored: %v", store.docs)
+	}
+}
+
+func TestSnapshotTargets(t *testing.T) {
+	tests := []struct {
+		path string
+		body any
+		want []string
+	}{
+		{"/series/12", nil, []string{"/series/12"}},
+		{"/series/12/?x=1", nil, []string{"/series/12"}},
+		{"/movie/editor", map[string]any{"movieIds": []any{3.0}}, []string{"/movie/3"}},
+		{"/series/editor", map[string]any{"ids": []any{5.0}}, []string{"/series/5"}},
+		{"/series/editor", map[string]any{"seriesIds": []any{1.0, 2.0}, "qualityProfileId": 7.0}, []string{"/series/1", "/series/2"}},
+		{"/series/editor", map[string]any{"movieIds": []any{3.0}, "tags": []any{4.0}}, nil},
+		{"/episodefile/editor", map[string]any{"episodeFileIds": []any{8.0, 9.0}, "quality": map[string]any{}}, []string{"/episodefile/8", "/episodefile/9"}},
+		{"/moviefile/editor", map[string]any{"movieFileIds": []any{4.0}, "movieId": 2.0}, []string{"/moviefile/4"}},
+		{"/config/host", map[string]any{"id": 1.0}, nil},
+	}
+	for _, tt := range tests {
+		if got := snapshotTargets(tt.path, tt.body); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("snapshotTargets(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
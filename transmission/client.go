@@ -53,20 +53,9 @@ func (c *Client) call(ctx context.Context, method string, args any) (*rpcRespons
 
 	// Try the request, retry once if CSRF token is stale
 	for attempt := 0; attempt < 2; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(data))
+		req, err := c.newRequest(ctx, data)
 		if err != nil {
-			return nil, fmt.Errorf("creating request: %w", err)
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-		c.mu.Lock()
-		if c.csrfToken != "" {
-			req.Header.Set(csrfHeader, c.csrfToken)
-		}
-		c.mu.Unlock()
-
-		if c.username != "" {
-			req.SetBasicAuth(c.username, c.password)
+			return nil, err
 		}
 
 		resp, err := c.http.Do(req)
@@ -108,3 +97,31 @@ func (c *Client) call(ctx context.Context, method string, args any) (*rpcRespons
 
 	return nil, fmt.Errorf("failed after CSRF retry")
 }
+
+// Request builds the RPC request for method without sending it, for dry runs.
+func (c *Client) Request(ctx context.Context, method string, args any) (*http.Request, error) {
+	data, err := json.Marshal(rpcRequest{Method: method, Arguments: args})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+	return c.newRequest(ctx, data)
+}
+
+func (c *Client) newRequest(ctx context.Context, data []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	c.mu.Lock()
+	if c.csrfToken != "" {
+		req.Header.Set(csrfHeader, c.csrfToken)
+	}
+	c.mu.Unlock()
+
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return req, nil
+}
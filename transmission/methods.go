@@ -100,6 +100,15 @@ func (c *Client) FreeSpace(ctx context.Context, path string) (int64, error) {
 	return 0, fmt.Errorf("unexpected free-space response")
 }
 
+// SessionGet returns the session settings, including the daemon version.
+func (c *Client) SessionGet(ctx context.Context) (map[string]any, error) {
+	resp, err := c.call(ctx, "session-get", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Arguments, nil
+}
+
 // SessionStats returns session statistics.
 func (c *Client) SessionStats(ctx context.Context) (map[string]any, error) {
 	resp, err := c.call(ctx, "session-stats", nil)
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/internal"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// shutdownTimeout bounds how long in-flight tool calls get to finish after
+// SIGTERM before open connections are cut.
+const shutdownTimeout = 10 * time.Second
+
+// serveHTTP serves the MCP server over streamable HTTP at /mcp and legacy SSE
+// at /sse + /message on one listener, so several MCP hosts can share a single
+// process and its warm spec store. It returns once the listener has been shut
+// down after SIGINT or SIGTERM. Every route sits behind authn, which rejects
+// unknown bearer tokens once any clients are configured.
+func serveHTTP(s *server.MCPServer, addr string, authn *auth.Authenticator) error {
+	httpServer := &http.Server{Addr: addr}
+	streamable, sse := httpTransports(s, httpServer, authn)
+
+	if !authn.Enabled() {
+		internal.Logf("WARNING: no clients configured — anyone who can reach %s can call every tool with your API keys", addr)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.Serve(ln)
+	}()
+
+	internal.Logf("starting navigatorr MCP server (http) on %s — streamable HTTP at /mcp, SSE at /sse", ln.Addr())
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	internal.Logf("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// The SSE server closes its open event streams before shutting down the
+	// shared http.Server; without that, Shutdown waits out the full timeout
+	// on every connected SSE client. Both transports are shut down, each
+	// waiting for its in-flight requests on the shared listener.
+	for _, t := range []interface{ Shutdown(context.Context) error }{sse, streamable} {
+		if err := t.Shutdown(shutdownCtx); err != nil {
+			internal.Errorf("graceful shutdown: %v", err)
+			httpServer.Close()
+			break
+		}
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// httpTransports sets httpServer up to serve s over streamable HTTP at /mcp
+// and SSE at /sse + /message, every route behind authn.
+func httpTransports(s *server.MCPServer, httpServer *http.Server, authn *auth.Authenticator) (*server.StreamableHTTPServer, *server.SSEServer) {
+	streamable := server.NewStreamableHTTPServer(s,
+		server.WithStreamableHTTPServer(httpServer),
+	)
+	sse := server.NewSSEServer(s,
+		server.WithHTTPServer(httpServer),
+		server.WithKeepAlive(true),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", streamable)
+	mux.Handle("/sse", sse.SSEHandler())
+	mux.Handle("/message", sse.MessageHandler())
+	httpServer.Handler = authn.Middleware(mux)
+	return streamable, sse
+}
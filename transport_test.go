@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jakenesler/navigatorr/auth"
+	"github.com/jakenesler/navigatorr/config"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// An MCP host can open a session over streamable HTTP at /mcp, and the route
+// sits behind the bearer token check.
+func TestHTTPTransportInitialize(t *testing.T) {
+	s := server.NewMCPServer("navigatorr", "1.0.0", server.WithToolCapabilities(true))
+	authn := auth.New(map[string]config.ClientConfig{"laptop": {Token: "t1"}})
+	httpServer := &http.Server{}
+	httpTransports(s, httpServer, authn)
+	srv := httptest.NewServer(httpServer.Handler)
+	defer srv.Close()
+
+	initialize := func(token string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("POST", srv.URL+"/mcp", strings.NewReader(`{"jsonrpc": "2.0", "id": 1, "method": "initialize",
+		  "params": {"protocolVersion": "2025-03-26", "capabilities": {}, "clientInfo": {"name": "test", "version": "0"}}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := initialize(""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without a token: HTTP %d, want 401", resp.StatusCode)
+	}
+
+	resp := initialize("t1")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize: HTTP %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Mcp-Session-Id") == "" {
+		t.Error("no session id in the initialize response")
+	}
+	var msg struct {
+		Result struct {
+			ServerInfo struct{ Name string } `json:"serverInfo"`
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Result.ServerInfo.Name != "navigatorr" {
+		t.Errorf("serverInfo = %+v", msg.Result.ServerInfo)
+	}
+}